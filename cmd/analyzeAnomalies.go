@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/jeffbrennan/mario/pkg/mario"
+	"github.com/spf13/cobra"
+)
+
+var analyzeAnomaliesCmd = &cobra.Command{
+	Use:   "anomalies",
+	Short: "list runs whose duration is unusual for their pipeline, most severe first",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+		threshold, _ := cmd.Flags().GetFloat64("threshold")
+
+		if threshold <= 0 {
+			return errors.New("threshold must be positive")
+		}
+
+		useProfilesFlag(cmd)
+		mario.AnalyzeAnomalies(getRunQuery(cmd), name, threshold)
+		return nil
+	},
+}
+
+func init() {
+	analyzeCmd.AddCommand(analyzeAnomaliesCmd)
+	acceptOutputFormats(analyzeAnomaliesCmd)
+	addRunQueryFlags(analyzeAnomaliesCmd, 14)
+	addProfilesFlag(analyzeAnomaliesCmd)
+	analyzeAnomaliesCmd.PersistentFlags().
+		String("name", "", "substring of the pipelines to analyze")
+	analyzeAnomaliesCmd.PersistentFlags().
+		Float64("threshold", 3.5, "robust z-score beyond which a run is anomalous")
+}
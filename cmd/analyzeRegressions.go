@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/jeffbrennan/mario/pkg/mario"
+	"github.com/spf13/cobra"
+)
+
+var analyzeRegressionsCmd = &cobra.Command{
+	Use:   "regressions",
+	Short: "find when each pipeline's duration shifted to a new level",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+		minRuns, _ := cmd.Flags().GetInt("min-runs")
+		minChange, _ := cmd.Flags().GetFloat64("min-change")
+
+		if minRuns < 2 {
+			return errors.New("min-runs must be at least 2")
+		}
+
+		useProfilesFlag(cmd)
+		mario.AnalyzeRegressions(getRunQuery(cmd), name, minRuns, minChange)
+		return nil
+	},
+}
+
+func init() {
+	analyzeCmd.AddCommand(analyzeRegressionsCmd)
+	acceptOutputFormats(analyzeRegressionsCmd)
+	addRunQueryFlags(analyzeRegressionsCmd, 30)
+	addProfilesFlag(analyzeRegressionsCmd)
+	analyzeRegressionsCmd.PersistentFlags().
+		String("name", "", "substring of the pipelines to analyze")
+	analyzeRegressionsCmd.PersistentFlags().
+		Int("min-runs", 5, "fewest successful runs on each side of a shift")
+	analyzeRegressionsCmd.PersistentFlags().
+		Float64("min-change", 10, "smallest change in median duration, in percent, that counts as a shift")
+}
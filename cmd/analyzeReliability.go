@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"github.com/jeffbrennan/mario/pkg/mario"
+	"github.com/spf13/cobra"
+)
+
+var analyzeReliabilityCmd = &cobra.Command{
+	Use:   "reliability",
+	Short: "rank pipelines by flakiness, failure streaks, MTBF and MTTR",
+	Run: func(cmd *cobra.Command, args []string) {
+		name, _ := cmd.Flags().GetString("name")
+		useProfilesFlag(cmd)
+		mario.AnalyzeReliability(getRunQuery(cmd), name)
+	},
+}
+
+func init() {
+	analyzeCmd.AddCommand(analyzeReliabilityCmd)
+	acceptOutputFormats(analyzeReliabilityCmd)
+	addRunQueryFlags(analyzeReliabilityCmd, 14)
+	addProfilesFlag(analyzeReliabilityCmd)
+	analyzeReliabilityCmd.PersistentFlags().
+		String("name", "", "substring of the pipelines to analyze")
+}
@@ -9,21 +9,25 @@ var analyzeTimeseriesCmd = &cobra.Command{
 	Use:   "timeseries",
 	Short: "print a timeseries of runs for a pipeline",
 	Run: func(cmd *cobra.Command, args []string) {
-		nDays, _ := cmd.Flags().GetInt("days")
 		name, _ := cmd.Flags().GetString("name")
+		drill, _ := cmd.Flags().GetBool("drill")
 
 		if name == "" {
 			panic("name is required")
 		}
 
-		mario.AnalyzeRuns(nDays, name)
+		useProfilesFlag(cmd)
+		mario.AnalyzeRuns(getRunQuery(cmd), name, drill)
 	},
 }
 
 func init() {
 	analyzeCmd.AddCommand(analyzeTimeseriesCmd)
-	analyzeTimeseriesCmd.PersistentFlags().
-		Int("days", 7, "number of days to analyze")
+	acceptOutputFormats(analyzeTimeseriesCmd)
+	addRunQueryFlags(analyzeTimeseriesCmd, 7)
+	addProfilesFlag(analyzeTimeseriesCmd)
 	analyzeTimeseriesCmd.PersistentFlags().
 		String("name", "", "name of pipeline")
+	analyzeTimeseriesCmd.PersistentFlags().
+		Bool("drill", false, "show the activity runs under each pipeline run")
 }
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/jeffbrennan/mario/pkg/mario"
+	"github.com/spf13/cobra"
+)
+
+var cancelCmd = &cobra.Command{
+	Use:   "cancel",
+	Short: "cancel a pipeline run, or every active run of matching pipelines",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runID, _ := cmd.Flags().GetString("run-id")
+		name, _ := cmd.Flags().GetString("name")
+		statuses, _ := cmd.Flags().GetStringSlice("status")
+		recursive, _ := cmd.Flags().GetBool("recursive")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		// an empty --name would match every pipeline
+		if runID == "" && name == "" {
+			return errors.New("one of --run-id or --name is required")
+		}
+
+		mario.Cancel(mario.CancelOptions{
+			RunID:     runID,
+			Name:      name,
+			Statuses:  statuses,
+			Query:     getRunQuery(cmd),
+			Recursive: recursive,
+			Yes:       yes,
+		})
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(cancelCmd)
+	cancelCmd.PersistentFlags().
+		String("run-id", "", "id of the pipeline run to cancel")
+	cancelCmd.PersistentFlags().
+		String("name", "", "substring of the pipelines whose runs are cancelled")
+	cancelCmd.PersistentFlags().
+		StringSlice("status", []string{"Queued", "InProgress"}, "statuses of the runs to cancel")
+	cancelCmd.PersistentFlags().
+		Bool("recursive", false, "also cancel child pipelines started by the run")
+	cancelCmd.PersistentFlags().
+		BoolP("yes", "y", false, "skip the confirmation prompt")
+	addRunQueryFlags(cancelCmd, 1)
+	cancelCmd.MarkFlagsOneRequired("run-id", "name")
+	cancelCmd.MarkFlagsMutuallyExclusive("run-id", "name")
+}
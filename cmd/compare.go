@@ -1,24 +1,102 @@
 package cmd
 
 import (
+	"errors"
+
 	"github.com/jeffbrennan/mario/pkg/mario"
 	"github.com/spf13/cobra"
 )
 
 var compareCmd = &cobra.Command{
 	Use:   "compare",
-	Short: "compare the contents of two pipelines",
-	Run: func(cmd *cobra.Command, args []string) {
+	Short: "compare the contents of two pipelines, deployed or in local JSON files",
+	// errors exit 2 and differences 1, without usage text
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		name1, _ := cmd.Flags().GetString("name1")
 		name2, _ := cmd.Flags().GetString("name2")
-		mario.Compare(name1, name2)
+		file1, _ := cmd.Flags().GetString("file1")
+		file2, _ := cmd.Flags().GetString("file2")
+
+		// --name and --file compare a deployed pipeline with a local file
+		if name, _ := cmd.Flags().GetString("name"); name != "" {
+			name1 = name
+		}
+		if file, _ := cmd.Flags().GetString("file"); file != "" {
+			file2 = file
+		}
+
+		if (name1 == "") == (file1 == "") {
+			return compareFailed(errors.New("exactly one of name1 or file1 is required"))
+		}
+		if (name2 == "") == (file2 == "") {
+			return compareFailed(errors.New("exactly one of name2 or file2 is required"))
+		}
+
+		if err := useCompareFlags(cmd); err != nil {
+			return compareFailed(err)
+		}
+		return compareResult(mario.Compare(
+			mario.PipelineRef{Name: name1, File: file1},
+			mario.PipelineRef{Name: name2, File: file2},
+		))
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(compareCmd)
-	compareCmd.PersistentFlags().
+	acceptOutputFormats(compareCmd)
+	compareCmd.Flags().
 		String("name1", "", "the first pipeline to compare")
-	compareCmd.PersistentFlags().
+	compareCmd.Flags().
 		String("name2", "", "the second pipeline to compare")
+	compareCmd.Flags().
+		String("file1", "", "a pipeline JSON file to use as the first pipeline")
+	compareCmd.Flags().
+		String("file2", "", "a pipeline JSON file to use as the second pipeline")
+	compareCmd.Flags().
+		String("name", "", "deployed pipeline to compare with --file, same as --name1")
+	compareCmd.Flags().
+		String("file", "", "pipeline JSON file to compare with --name, same as --file2")
+	compareCmd.PersistentFlags().
+		StringSlice("ignore", nil, "paths whose differences are expected, e.g. properties.activities[*].policy.timeout")
+	compareCmd.PersistentFlags().
+		String("format", "summary", "how to print differences: summary, unified or side-by-side")
+	compareCmd.PersistentFlags().
+		Int("context", 3, "unchanged lines around each change in the unified and side-by-side formats")
+	compareCmd.PersistentFlags().
+		String("report", "", "print a markdown or github report instead of the table, for CI")
+}
+
+// useCompareFlags passes the flags compare and its subcommands share.
+func useCompareFlags(cmd *cobra.Command) error {
+	patterns, _ := cmd.Flags().GetStringSlice("ignore")
+	mario.UseIgnorePatterns(patterns)
+
+	format, _ := cmd.Flags().GetString("format")
+	context, _ := cmd.Flags().GetInt("context")
+	if err := mario.UseDiffFormat(format, context); err != nil {
+		return err
+	}
+
+	report, _ := cmd.Flags().GetString("report")
+	return mario.UseReport(report)
+}
+
+// compareResult makes mario exit 0 when the comparison found no
+// differences, 1 when it did, and 2 when it could not be made, so CI can
+// tell drift from failure.
+func compareResult(differs bool, err error) error {
+	if err != nil {
+		return compareFailed(err)
+	}
+	if differs {
+		return exitError{code: 1}
+	}
+	return nil
+}
+
+func compareFailed(err error) error {
+	return exitError{code: 2, err: err}
 }
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"github.com/jeffbrennan/mario/pkg/mario"
+	"github.com/spf13/cobra"
+)
+
+var compareFactoriesCmd = &cobra.Command{
+	Use:   "factories",
+	Short: "list pipelines that are missing or differ between two factories",
+	// errors exit 2 and differences 1, without usage text
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+		include, _ := cmd.Flags().GetStringSlice("include")
+
+		if err := useCompareFlags(cmd); err != nil {
+			return compareFailed(err)
+		}
+		return compareResult(mario.CompareFactories(from, to, include))
+	},
+}
+
+func init() {
+	compareCmd.AddCommand(compareFactoriesCmd)
+	acceptOutputFormats(compareFactoriesCmd)
+	compareFactoriesCmd.PersistentFlags().
+		String("from", "", "profile of the factory changes are promoted from, e.g. dev")
+	compareFactoriesCmd.PersistentFlags().
+		String("to", "", "profile of the factory changes are promoted to, e.g. prod")
+	compareFactoriesCmd.PersistentFlags().
+		StringSlice("include", nil, "also compare datasets, linkedServices and triggers")
+}
@@ -1,15 +1,16 @@
 package cmd
 
 import (
-	"github.com/jeffbrennan/mario/pkg/mario"
+	"fmt"
+
 	"github.com/spf13/cobra"
 )
 
 var configCmd = &cobra.Command{
 	Use:   "config",
-	Short: "add azure environment details to the CLI",
+	Short: "manage the azure environment profiles",
 	Run: func(cmd *cobra.Command, args []string) {
-		mario.HelloConfig()
+		fmt.Println("pick a subcommand")
 	},
 }
 
@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"github.com/jeffbrennan/mario/pkg/mario"
+	"github.com/spf13/cobra"
+)
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list profiles, the current one is marked with *",
+	Run: func(cmd *cobra.Command, args []string) {
+		mario.ListProfiles()
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configListCmd)
+	acceptOutputFormats(configListCmd)
+}
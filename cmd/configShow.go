@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"github.com/jeffbrennan/mario/pkg/mario"
+	"github.com/spf13/cobra"
+)
+
+var configShowCmd = &cobra.Command{
+	Use:   "show [profile]",
+	Short: "print a profile, the selected one by default",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		resolved, _ := cmd.Flags().GetBool("resolved")
+
+		if resolved {
+			mario.ShowResolvedConfig()
+			return
+		}
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		mario.ShowProfile(name)
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configShowCmd)
+	configShowCmd.PersistentFlags().
+		Bool("resolved", false, "show the settings after flags, env vars and config files are layered, and where each came from")
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"github.com/jeffbrennan/mario/pkg/mario"
+	"github.com/spf13/cobra"
+)
+
+var configUseCmd = &cobra.Command{
+	Use:   "use [profile]",
+	Short: "use a profile when --profile is not set",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		global, _ := cmd.Flags().GetBool("global")
+
+		mario.UseProfileByDefault(args[0], global)
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configUseCmd)
+	configUseCmd.PersistentFlags().
+		Bool("global", false, "set the current profile in the user config instead of .mario.yaml")
+}
@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"github.com/jeffbrennan/mario/pkg/mario"
+	"github.com/spf13/cobra"
+)
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "check every profile for missing or malformed settings",
+	Run: func(cmd *cobra.Command, args []string) {
+		mario.ValidateConfig()
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+}
@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var devCmd = &cobra.Command{
+	Use:   "dev",
+	Short: "tools for developing and testing mario",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("pick a subcommand")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(devCmd)
+}
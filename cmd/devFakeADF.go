@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"github.com/jeffbrennan/mario/pkg/mario"
+	"github.com/spf13/cobra"
+)
+
+var devFakeADFCmd = &cobra.Command{
+	Use:   "fake-adf",
+	Short: "serve a local stand-in for the Data Factory REST API",
+	Run: func(cmd *cobra.Command, args []string) {
+		addr, _ := cmd.Flags().GetString("addr")
+		dir, _ := cmd.Flags().GetString("dir")
+		speed, _ := cmd.Flags().GetFloat64("speed")
+		pageSize, _ := cmd.Flags().GetInt("page-size")
+
+		mario.ServeFakeADF(mario.FakeADFOptions{
+			Addr:     addr,
+			Dir:      dir,
+			Speed:    speed,
+			PageSize: pageSize,
+		})
+	},
+}
+
+func init() {
+	devCmd.AddCommand(devFakeADFCmd)
+	devFakeADFCmd.PersistentFlags().
+		String("addr", "127.0.0.1:8443", "address to listen on")
+	devFakeADFCmd.PersistentFlags().
+		String("dir", "setup/fixtures", "fixture directory to serve")
+	devFakeADFCmd.PersistentFlags().
+		Float64("speed", 1, "how much faster than real time simulated runs progress")
+	devFakeADFCmd.PersistentFlags().
+		Int("page-size", 100, "number of runs returned per queryPipelineRuns page")
+}
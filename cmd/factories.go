@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var factoriesCmd = &cobra.Command{
+	Use:   "factories",
+	Short: "find the data factories the current credential can see",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("pick a subcommand")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(factoriesCmd)
+}
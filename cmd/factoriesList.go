@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"github.com/jeffbrennan/mario/pkg/mario"
+	"github.com/spf13/cobra"
+)
+
+var factoriesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list every data factory across all accessible subscriptions",
+	Run: func(cmd *cobra.Command, args []string) {
+		save, _ := cmd.Flags().GetBool("save")
+		global, _ := cmd.Flags().GetBool("global")
+
+		mario.ListFactories(save, global)
+	},
+}
+
+func init() {
+	factoriesCmd.AddCommand(factoriesListCmd)
+	acceptOutputFormats(factoriesListCmd)
+	factoriesListCmd.PersistentFlags().
+		Bool("save", false, "pick one of the listed factories to save as a profile")
+	factoriesListCmd.PersistentFlags().
+		Bool("global", false, "save the profile to the user config instead of .mario.yaml")
+}
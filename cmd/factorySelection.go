@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"github.com/jeffbrennan/mario/pkg/mario"
+	"github.com/spf13/cobra"
+)
+
+// addProfilesFlag registers --profiles on commands that can aggregate
+// several factories.
+func addProfilesFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().
+		StringSlice("profiles", nil, "profiles or profile groups to query together, e.g. dev,prod or all")
+}
+
+func useProfilesFlag(cmd *cobra.Command) {
+	profiles, _ := cmd.Flags().GetStringSlice("profiles")
+	mario.UseFactories(profiles)
+}
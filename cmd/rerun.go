@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/jeffbrennan/mario/pkg/mario"
+	"github.com/spf13/cobra"
+)
+
+var rerunCmd = &cobra.Command{
+	Use:   "rerun",
+	Short: "rerun a pipeline run, or the latest failed run of each pipeline",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runID, _ := cmd.Flags().GetString("run-id")
+		failed, _ := cmd.Flags().GetBool("failed")
+		name, _ := cmd.Flags().GetString("name")
+		fromFailure, _ := cmd.Flags().GetBool("from-failure")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		// the flag groups below catch most misuse, but not --failed=false
+		if runID == "" && !failed {
+			return errors.New("one of --run-id or --failed is required")
+		}
+
+		mario.Rerun(mario.RerunOptions{
+			RunID:       runID,
+			Failed:      failed,
+			Name:        name,
+			Query:       getRunQuery(cmd),
+			FromFailure: fromFailure,
+			DryRun:      dryRun,
+			Yes:         yes,
+		})
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(rerunCmd)
+	rerunCmd.PersistentFlags().
+		String("run-id", "", "id of the pipeline run to rerun")
+	rerunCmd.PersistentFlags().
+		Bool("failed", false, "rerun every pipeline whose latest run failed")
+	rerunCmd.PersistentFlags().
+		String("name", "", "substring of the pipelines to rerun with --failed, every pipeline when empty")
+	rerunCmd.PersistentFlags().
+		Bool("from-failure", false, "restart from the failed activities instead of the start")
+	rerunCmd.PersistentFlags().
+		Bool("dry-run", false, "show the runs that would be started without starting them")
+	rerunCmd.PersistentFlags().
+		BoolP("yes", "y", false, "skip the confirmation prompt")
+	addRunQueryFlags(rerunCmd, 1)
+	rerunCmd.MarkFlagsOneRequired("run-id", "failed")
+	rerunCmd.MarkFlagsMutuallyExclusive("run-id", "failed")
+	rerunCmd.MarkFlagsMutuallyExclusive("run-id", "name")
+}
@@ -1,22 +1,116 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
+	"github.com/jeffbrennan/mario/pkg/mario"
 	"github.com/spf13/cobra"
 )
 
 var RootCmd = &cobra.Command{
 	Use:   "mario",
 	Short: "Mario - an ADF monitoring tool",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+		if err := mario.UseOutput(output); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if output != "table" && cmd.Annotations[outputAnnotation] == "" {
+			fmt.Printf("%s only prints tables, --output %s is not supported\n", cmd.CommandPath(), output)
+			os.Exit(1)
+		}
+
+		fixtures, _ := cmd.Flags().GetStringSlice("fixtures")
+		mario.UseFixtures(fixtures)
+
+		endpoint, _ := cmd.Flags().GetString("endpoint")
+		if endpoint == "" {
+			endpoint = os.Getenv("MARIO_ENDPOINT")
+		}
+		mario.UseEndpoint(endpoint)
+
+		profile, _ := cmd.Flags().GetString("profile")
+		profileSource := "flag --profile"
+		if profile == "" {
+			profile = os.Getenv("MARIO_PROFILE")
+			profileSource = "env MARIO_PROFILE"
+		}
+		mario.UseProfile(profile, profileSource)
+
+		subscription, _ := cmd.Flags().GetString("subscription")
+		resourceGroup, _ := cmd.Flags().GetString("resource-group")
+		factory, _ := cmd.Flags().GetString("factory")
+		mario.UseFactoryOverrides(mario.AZEnv{
+			SubscriptionID:    subscription,
+			ResourceGroupName: resourceGroup,
+			DataFactoryName:   factory,
+		})
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 	},
 }
 
+// outputAnnotation marks the commands that can print the --output formats
+// other than table. The rest reject them rather than print a table anyway.
+const outputAnnotation = "output"
+
+func acceptOutputFormats(cmd *cobra.Command) {
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	cmd.Annotations[outputAnnotation] = "true"
+}
+
+// exitError makes mario exit with code once the command has returned,
+// printing err to stderr when set. Commands return it rather than calling
+// os.Exit, so mario shell keeps running after them.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+	return e.err.Error()
+}
+
+func (e exitError) Unwrap() error {
+	return e.err
+}
+
 func Execute() {
-	if err := RootCmd.Execute(); err != nil {
-		fmt.Println(err)
+	err := RootCmd.Execute()
+	var exit exitError
+	if errors.As(err, &exit) {
+		if exit.err != nil {
+			fmt.Fprintln(os.Stderr, exit.err)
+		}
+		os.Exit(exit.code)
+	}
+	// cobra has already printed any other error
+	if err != nil {
 		os.Exit(1)
 	}
 }
+
+func init() {
+	RootCmd.PersistentFlags().
+		StringSlice("fixtures", nil, "read pipelines and runs from fixture directories instead of Azure")
+	RootCmd.PersistentFlags().
+		String("endpoint", "", "Resource Manager endpoint to use instead of Azure, e.g. from mario dev fake-adf; only loopback endpoints skip auth (env MARIO_ENDPOINT)")
+	RootCmd.PersistentFlags().
+		String("profile", "", "profile to use instead of the current one (env MARIO_PROFILE)")
+	RootCmd.PersistentFlags().
+		String("subscription", "", "Azure subscription id, overrides env AZ_SUBSCRIPTION_ID and the profile")
+	RootCmd.PersistentFlags().
+		String("resource-group", "", "resource group of the factory, overrides env AZ_RESOURCE_GROUP and the profile")
+	RootCmd.PersistentFlags().
+		String("factory", "", "data factory name, overrides env AZ_DATAFACTORY_NAME and the profile")
+	RootCmd.PersistentFlags().
+		StringP("output", "o", "table", "output format: table, json, csv, markdown or yaml")
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"github.com/jeffbrennan/mario/pkg/mario"
+	"github.com/spf13/cobra"
+)
+
+// addRunQueryFlags registers the flags shared by every command that reads
+// pipeline runs.
+func addRunQueryFlags(cmd *cobra.Command, defaultDays int) {
+	cmd.PersistentFlags().
+		Int("days", defaultDays, "number of days of runs to read, ignored when --from is set")
+	cmd.PersistentFlags().
+		String("from", "", "start of the range: a timestamp like 2024-03-01 or 2024-03-01T06:00, or an offset like -2w, -3d, -12h")
+	cmd.PersistentFlags().
+		String("to", "", "end of the range, same formats as --from (default now)")
+	cmd.PersistentFlags().
+		String("tz", "", "IANA timezone used to read and print timestamps (default local)")
+	cmd.PersistentFlags().
+		Int("max-runs", 0, "read only this many of the most recent runs (0 reads every page)")
+}
+
+func getRunQuery(cmd *cobra.Command) mario.RunQuery {
+	nDays, _ := cmd.Flags().GetInt("days")
+	from, _ := cmd.Flags().GetString("from")
+	to, _ := cmd.Flags().GetString("to")
+	timezone, _ := cmd.Flags().GetString("tz")
+	maxRuns, _ := cmd.Flags().GetInt("max-runs")
+
+	// explicit flags win over the defaults of the selected profile
+	defaults := mario.GetProfileDefaults()
+	if !cmd.Flags().Changed("days") && defaults.Days > 0 {
+		nDays = defaults.Days
+	}
+	if !cmd.Flags().Changed("tz") && defaults.Timezone != "" {
+		timezone = defaults.Timezone
+	}
+
+	return mario.RunQuery{
+		Days:     nDays,
+		From:     from,
+		To:       to,
+		Timezone: timezone,
+		MaxRuns:  maxRuns,
+	}
+}
@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var runsCmd = &cobra.Command{
+	Use:   "runs",
+	Short: "inspect individual pipeline runs",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("pick a subcommand")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(runsCmd)
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"github.com/jeffbrennan/mario/pkg/mario"
+	"github.com/spf13/cobra"
+)
+
+var runsActivitiesCmd = &cobra.Command{
+	Use:   "activities",
+	Short: "show the activity runs of a pipeline run in dependency order",
+	Run: func(cmd *cobra.Command, args []string) {
+		runID, _ := cmd.Flags().GetString("run-id")
+		mario.ShowActivityRuns(runID)
+	},
+}
+
+func init() {
+	runsCmd.AddCommand(runsActivitiesCmd)
+	acceptOutputFormats(runsActivitiesCmd)
+	runsActivitiesCmd.PersistentFlags().
+		String("run-id", "", "id of the pipeline run")
+	runsActivitiesCmd.MarkPersistentFlagRequired("run-id")
+}
@@ -11,11 +11,15 @@ var configSetupCmd = &cobra.Command{
 	Use:   "setup",
 	Short: "add azure environment details to the CLI",
 	Run: func(cmd *cobra.Command, args []string) {
+		global, _ := cmd.Flags().GetBool("global")
+
 		fmt.Println("This is the config setup command")
-		mario.ConfigSetup()
+		mario.ConfigSetup(global)
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(configSetupCmd)
+	configSetupCmd.PersistentFlags().
+		Bool("global", false, "write the profile to the user config instead of .mario.yaml")
 }
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -62,7 +63,7 @@ func Shell() {
 					}
 				case "compare":
 					compareCmd.ParseFlags(args)
-					compareCmd.Run(compareCmd, nil)
+					printShellError(compareCmd.RunE(compareCmd, nil))
 
 				case "exit":
 					exitCmd.Run(exitCmd, nil)
@@ -81,3 +82,15 @@ func Shell() {
 func init() {
 	RootCmd.AddCommand(shellCmd)
 }
+
+// printShellError reports a command's error and carries on, since an exit
+// code means nothing inside the shell.
+func printShellError(err error) {
+	var exit exitError
+	if errors.As(err, &exit) && exit.err == nil {
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+}
@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var slaCmd = &cobra.Command{
+	Use:   "sla",
+	Short: "check pipelines against the SLAs in the config",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("pick a subcommand")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(slaCmd)
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"github.com/jeffbrennan/mario/pkg/mario"
+	"github.com/spf13/cobra"
+)
+
+var slaCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "print met, missed and at-risk SLAs for recent runs, exiting 1 on a miss and 2 on an error",
+	// errors exit 2 and misses 1, without usage text
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		historyDays, _ := cmd.Flags().GetInt("history-days")
+
+		useProfilesFlag(cmd)
+		missed, err := mario.CheckSLAs(getRunQuery(cmd), historyDays)
+		if err != nil {
+			return exitError{code: 2, err: err}
+		}
+		if missed {
+			return exitError{code: 1}
+		}
+		return nil
+	},
+}
+
+func init() {
+	slaCmd.AddCommand(slaCheckCmd)
+	acceptOutputFormats(slaCheckCmd)
+	addRunQueryFlags(slaCheckCmd, 1)
+	addProfilesFlag(slaCheckCmd)
+	slaCheckCmd.PersistentFlags().
+		Int("history-days", 7, "days of successful runs used to estimate when in progress runs finish")
+}
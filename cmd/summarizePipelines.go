@@ -9,10 +9,13 @@ var summarizePipelinesCmd = &cobra.Command{
 	Use:   "pipelines",
 	Short: "summarize pipeline information",
 	Run: func(cmd *cobra.Command, args []string) {
+		useProfilesFlag(cmd)
 		mario.SummarizePipelines()
 	},
 }
 
 func init() {
 	summarizeCmd.AddCommand(summarizePipelinesCmd)
+	acceptOutputFormats(summarizePipelinesCmd)
+	addProfilesFlag(summarizePipelinesCmd)
 }
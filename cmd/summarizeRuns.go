@@ -9,16 +9,20 @@ var summarizeRunsCmd = &cobra.Command{
 	Use:   "runs",
 	Short: "summarize pipeline runs",
 	Run: func(cmd *cobra.Command, args []string) {
-		nDays, _ := cmd.Flags().GetInt("days")
 		name, _ := cmd.Flags().GetString("name")
-		mario.SummarizeRuns(nDays, name)
+		stats, _ := cmd.Flags().GetStringSlice("stats")
+		useProfilesFlag(cmd)
+		mario.SummarizeRuns(getRunQuery(cmd), name, stats)
 	},
 }
 
 func init() {
 	summarizeCmd.AddCommand(summarizeRunsCmd)
-	summarizeRunsCmd.PersistentFlags().
-		Int("days", 7, "number of days to summarize")
+	acceptOutputFormats(summarizeRunsCmd)
+	addRunQueryFlags(summarizeRunsCmd, 7)
+	addProfilesFlag(summarizeRunsCmd)
 	summarizeRunsCmd.PersistentFlags().
 		String("name", "", "substring of the pipeline to summarize")
+	summarizeRunsCmd.PersistentFlags().
+		StringSlice("stats", []string{"avg", "total"}, "columns to show: avg, total, p50, p90, p95, max, stddev, success-rate, last. Runtimes cover succeeded runs, except total which covers every finished run")
 }
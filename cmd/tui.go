@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/jeffbrennan/mario/pkg/mario"
+	"github.com/spf13/cobra"
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "browse pipelines, runs and activities in a full-screen interface",
+	Run: func(cmd *cobra.Command, args []string) {
+		refresh, _ := cmd.Flags().GetDuration("refresh")
+		mario.RunTUI(getRunQuery(cmd), refresh)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(tuiCmd)
+	addRunQueryFlags(tuiCmd, 7)
+	tuiCmd.PersistentFlags().
+		Duration("refresh", 30*time.Second, "how often to reload runs, 0 disables auto-refresh")
+}
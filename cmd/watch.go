@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jeffbrennan/mario/pkg/mario"
+	"github.com/spf13/cobra"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "live view of queued and in progress runs",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		historyDays, _ := cmd.Flags().GetInt("history-days")
+
+		if interval <= 0 {
+			return errors.New("interval must be positive")
+		}
+		if historyDays <= 0 {
+			return errors.New("history-days must be positive")
+		}
+
+		mario.Watch(getRunQuery(cmd), name, interval, historyDays)
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(watchCmd)
+	addRunQueryFlags(watchCmd, 7)
+	watchCmd.PersistentFlags().
+		String("name", "", "substring of the pipelines to watch")
+	watchCmd.PersistentFlags().
+		Duration("interval", 10*time.Second, "how often to poll for run updates")
+	watchCmd.PersistentFlags().
+		Int("history-days", 7, "days of successful runs used to estimate progress")
+}
@@ -0,0 +1,517 @@
+package mario
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/datafactory/armdatafactory/v3"
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+)
+
+// activityNode is one activity in a pipeline run, placed under the first
+// activity it depends on.
+type activityNode struct {
+	name      string
+	dependsOn []string
+	runs      []*armdatafactory.ActivityRun
+	children  []*activityNode
+}
+
+type activityRow struct {
+	label string
+	run   *armdatafactory.ActivityRun
+}
+
+func ShowActivityRuns(runID string) {
+	defer timer("ShowActivityRuns")()
+	factory := getFactoryClient()
+	ctx := context.Background()
+
+	pipelineRun, err := factory.source.GetPipelineRun(ctx, runID)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tree := getActivityTree(&factory, ctx, pipelineRun, activityDefinitions{})
+	if !tableOutput() {
+		if err := writeRecords(activityRecords(pipelineRun, tree, 0)); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	printActivityRuns(pipelineRun, tree)
+}
+
+// ActivityRunRecord is one activity run of a pipeline run in the non-table
+// outputs, in tree order. Depth is how far the activity is nested under the
+// activities it depends on. Activities that never ran have a row without a
+// run id or status.
+type ActivityRunRecord struct {
+	PipelineRunID string    `json:"pipelineRunId" yaml:"pipelineRunId"`
+	Activity      string    `json:"activity" yaml:"activity"`
+	Depth         int       `json:"depth" yaml:"depth"`
+	DependsOn     []string  `json:"dependsOn" yaml:"dependsOn"`
+	ActivityRunID string    `json:"activityRunId" yaml:"activityRunId"`
+	Type          string    `json:"type" yaml:"type"`
+	Status        string    `json:"status" yaml:"status"`
+	StartTime     time.Time `json:"startTime" yaml:"startTime"`
+	EndTime       time.Time `json:"endTime" yaml:"endTime"`
+	DurationMs    int32     `json:"durationMs" yaml:"durationMs"`
+	ErrorCode     string    `json:"errorCode" yaml:"errorCode"`
+	Error         string    `json:"error" yaml:"error"`
+}
+
+func activityRecords(
+	pipelineRun armdatafactory.PipelineRun,
+	nodes []*activityNode,
+	depth int,
+) []ActivityRunRecord {
+	records := []ActivityRunRecord{}
+	for _, node := range nodes {
+		record := ActivityRunRecord{
+			PipelineRunID: stringValue(pipelineRun.RunID),
+			Activity:      node.name,
+			Depth:         depth,
+			DependsOn:     node.dependsOn,
+		}
+		if len(node.runs) == 0 {
+			records = append(records, record)
+		}
+		for _, activityRun := range node.runs {
+			record.ActivityRunID = stringValue(activityRun.ActivityRunID)
+			record.Type = stringValue(activityRun.ActivityType)
+			record.Status = stringValue(activityRun.Status)
+			record.StartTime = timeValue(activityRun.ActivityRunStart)
+			record.EndTime = timeValue(activityRun.ActivityRunEnd)
+			record.DurationMs = int32Value(activityRun.DurationInMs)
+			record.ErrorCode, record.Error = activityRunError(activityRun)
+			records = append(records, record)
+		}
+		records = append(records, activityRecords(pipelineRun, node.children, depth+1)...)
+	}
+	return records
+}
+
+// activityDefinitions caches the activities of each pipeline definition by
+// pipeline name, so drilling into many runs of a pipeline reads it once. It is
+// not safe for concurrent use.
+type activityDefinitions map[string][]activityDefinition
+
+func (cache activityDefinitions) get(
+	factory *Factory,
+	ctx context.Context,
+	pipelineName string,
+) []activityDefinition {
+	if definitions, cached := cache[pipelineName]; cached {
+		return definitions
+	}
+
+	// the definition may have been deleted or renamed since the run
+	definitions := []activityDefinition{}
+	pipeline, err := factory.source.GetPipeline(ctx, pipelineName)
+	if err == nil {
+		definitions = pipelineActivities(pipeline)
+	}
+	cache[pipelineName] = definitions
+	return definitions
+}
+
+// getActivityTree reads the activity runs of a pipeline run and arranges them
+// by the dependsOn order of the pipeline definition.
+func getActivityTree(
+	factory *Factory,
+	ctx context.Context,
+	pipelineRun armdatafactory.PipelineRun,
+	definitions activityDefinitions,
+) []*activityNode {
+	defer timer("getActivityTree")()
+	tree, err := activityTree(factory, ctx, pipelineRun, definitions)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return tree
+}
+
+// activityTree is getActivityTree for callers that handle the error.
+func activityTree(
+	factory *Factory,
+	ctx context.Context,
+	pipelineRun armdatafactory.PipelineRun,
+	definitions activityDefinitions,
+) ([]*activityNode, error) {
+	activityRuns, err := getActivityRuns(factory, ctx, pipelineRun)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildActivityTree(
+		definitions.get(factory, ctx, stringValue(pipelineRun.PipelineName)),
+		activityRuns,
+	), nil
+}
+
+func getActivityRuns(
+	factory *Factory,
+	ctx context.Context,
+	pipelineRun armdatafactory.PipelineRun,
+) ([]*armdatafactory.ActivityRun, error) {
+	// activity runs are filtered by when they were last updated, which falls
+	// somewhere between the start of the pipeline run and now
+	runsFrom := time.Now().Add(-runRetention)
+	if pipelineRun.RunStart != nil {
+		runsFrom = pipelineRun.RunStart.Add(-time.Hour)
+	}
+	runsTo := time.Now().Add(time.Hour)
+
+	filter := armdatafactory.RunFilterParameters{
+		LastUpdatedAfter:  &runsFrom,
+		LastUpdatedBefore: &runsTo,
+	}
+
+	activityRuns := []*armdatafactory.ActivityRun{}
+	for {
+		response, err := factory.source.QueryActivityRuns(
+			ctx,
+			stringValue(pipelineRun.RunID),
+			filter,
+		)
+		if err != nil {
+			return nil, err
+		}
+		activityRuns = append(activityRuns, response.Value...)
+
+		if response.ContinuationToken == nil || *response.ContinuationToken == "" {
+			break
+		}
+		filter.ContinuationToken = response.ContinuationToken
+	}
+
+	slices.SortStableFunc(activityRuns, func(a, b *armdatafactory.ActivityRun) int {
+		return compareTimes(a.ActivityRunStart, b.ActivityRunStart)
+	})
+	return activityRuns, nil
+}
+
+func buildActivityTree(
+	definitions []activityDefinition,
+	activityRuns []*armdatafactory.ActivityRun,
+) []*activityNode {
+	nodes := map[string]*activityNode{}
+	ordered := []*activityNode{}
+
+	for _, definition := range orderActivities(definitions) {
+		node := &activityNode{
+			name:      definition.name,
+			dependsOn: definition.dependsOn,
+		}
+		nodes[definition.name] = node
+		ordered = append(ordered, node)
+	}
+
+	// activities missing from the definition, such as the inner activities
+	// of a ForEach, are listed at the top level in the order they ran
+	for _, activityRun := range activityRuns {
+		name := stringValue(activityRun.ActivityName)
+		node, exists := nodes[name]
+		if !exists {
+			node = &activityNode{name: name}
+			nodes[name] = node
+			ordered = append(ordered, node)
+		}
+		node.runs = append(node.runs, activityRun)
+	}
+
+	roots := []*activityNode{}
+	for _, node := range ordered {
+		var parent *activityNode
+		if len(node.dependsOn) > 0 {
+			parent = nodes[node.dependsOn[0]]
+		}
+		if parent != nil {
+			parent.children = append(parent.children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots
+}
+
+// flattenActivityTree turns the tree into rows with box drawing prefixes, one
+// row per activity run. Activities that never ran get a single empty row.
+func flattenActivityTree(nodes []*activityNode, prefix string) []activityRow {
+	rows := []activityRow{}
+	for i, node := range nodes {
+		branch, indent := "├── ", "│   "
+		if i == len(nodes)-1 {
+			branch, indent = "└── ", "    "
+		}
+
+		label := prefix + branch + node.name
+		if len(node.dependsOn) > 1 {
+			label += " (after " + strings.Join(node.dependsOn, ", ") + ")"
+		}
+
+		if len(node.runs) == 0 {
+			rows = append(rows, activityRow{label: label})
+		}
+		for _, activityRun := range node.runs {
+			rows = append(rows, activityRow{label: label, run: activityRun})
+		}
+
+		rows = append(rows, flattenActivityTree(node.children, prefix+indent)...)
+	}
+	return rows
+}
+
+func printActivityRuns(
+	pipelineRun armdatafactory.PipelineRun,
+	tree []*activityNode,
+) {
+	defer timer("printActivityRuns")()
+	headerLength := 80
+
+	header := createHeader(
+		"ACTIVITIES",
+		headerLength,
+		color.New(color.FgBlue),
+		"=",
+		true,
+	)
+	footer := createHeader("", headerLength, color.New(color.FgWhite), "=", true)
+
+	fmt.Print("\n", header, "\n")
+	color.New(color.Underline).Println(stringValue(pipelineRun.PipelineName))
+	fmt.Println(
+		"run",
+		stringValue(pipelineRun.RunID),
+		statusColor(stringValue(pipelineRun.Status))(stringValue(pipelineRun.Status)),
+	)
+	if message := stringValue(pipelineRun.Message); message != "" {
+		fmt.Println(message)
+	}
+	fmt.Println()
+
+	rows := flattenActivityTree(tree, "")
+	if len(rows) == 0 {
+		fmt.Println("No activity runs found")
+		fmt.Println(footer)
+		return
+	}
+
+	headerFmt := color.New(color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+	tbl := table.New(
+		"Activity",
+		"Type",
+		"Status",
+		"Start",
+		"End",
+		"Duration",
+		"Error Code",
+	)
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	failures := []activityRow{}
+	for _, row := range rows {
+		if row.run == nil {
+			tbl.AddRow(row.label, "", neutralColor()("Not run"), "", "", "", "")
+			continue
+		}
+
+		status := stringValue(row.run.Status)
+		code, message := activityRunError(row.run)
+		if message != "" {
+			failures = append(failures, row)
+		}
+
+		tbl.AddRow(
+			row.label,
+			stringValue(row.run.ActivityType),
+			statusColor(status)(status),
+			formatRunTime(row.run.ActivityRunStart),
+			formatRunTime(row.run.ActivityRunEnd),
+			formatDurationMs(row.run.DurationInMs),
+			code,
+		)
+	}
+	tbl.Print()
+
+	for _, failure := range failures {
+		code, message := activityRunError(failure.run)
+		fmt.Println()
+		fmt.Println(failureColor()(stringValue(failure.run.ActivityName) + " " + code))
+		fmt.Println(message)
+	}
+
+	fmt.Println(footer)
+}
+
+// printActivityTreeCompact prints one line per activity run, used under each
+// run of analyze timeseries --drill.
+func printActivityTreeCompact(tree []*activityNode, indent string) {
+	for _, row := range flattenActivityTree(tree, indent) {
+		if row.run == nil {
+			fmt.Println(row.label, neutralColor()("not run"))
+			continue
+		}
+
+		status := stringValue(row.run.Status)
+		code, message := activityRunError(row.run)
+		line := []any{
+			row.label,
+			statusColor(status)(status),
+			formatDurationMs(row.run.DurationInMs),
+		}
+		if code != "" {
+			line = append(line, failureColor()(code), truncate(message, 80))
+		}
+		fmt.Println(line...)
+	}
+}
+
+// activityRunError extracts the error code and message ADF reports for a
+// failed activity. Successful activities carry an error object with empty
+// fields.
+func activityRunError(activityRun *armdatafactory.ActivityRun) (string, string) {
+	errorMap, ok := activityRun.Error.(map[string]any)
+	if !ok {
+		return "", ""
+	}
+	code, _ := errorMap["errorCode"].(string)
+	message, _ := errorMap["message"].(string)
+	return code, message
+}
+
+func statusColor(status string) func(a ...interface{}) string {
+	switch status {
+	case "Succeeded":
+		return successColor()
+	case "Failed":
+		return failureColor()
+	case "Cancelled", "Canceling":
+		return color.New(color.FgYellow).SprintFunc()
+	case "InProgress", "Queued":
+		return color.New(color.FgCyan).SprintFunc()
+	}
+	return neutralColor()
+}
+
+func formatRunTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+func formatDurationMs(durationMs *int32) string {
+	if durationMs == nil {
+		return ""
+	}
+	duration := time.Duration(*durationMs) * time.Millisecond
+	return duration.Truncate(time.Second).String()
+}
+
+func truncate(s string, length int) string {
+	if len([]rune(s)) <= length {
+		return s
+	}
+	return string([]rune(s)[:length-3]) + "..."
+}
+
+// activityDefinition is the part of a pipeline activity mario cares about,
+// read from the raw JSON so every activity type is handled the same way.
+type activityDefinition struct {
+	name         string
+	activityType string
+	dependsOn    []string
+	timeout      string
+	notebookPath string
+}
+
+func pipelineActivities(
+	pipeline armdatafactory.PipelineResource,
+) []activityDefinition {
+	pipelineJson, _ := pipeline.MarshalJSON()
+	raw := struct {
+		Properties struct {
+			Activities []struct {
+				Name      string `json:"name"`
+				Type      string `json:"type"`
+				DependsOn []struct {
+					Activity string `json:"activity"`
+				} `json:"dependsOn"`
+				Policy struct {
+					Timeout any `json:"timeout"`
+				} `json:"policy"`
+				TypeProperties struct {
+					NotebookPath any `json:"notebookPath"`
+				} `json:"typeProperties"`
+			} `json:"activities"`
+		} `json:"properties"`
+	}{}
+	json.Unmarshal(pipelineJson, &raw)
+
+	activities := []activityDefinition{}
+	for _, activity := range raw.Properties.Activities {
+		definition := activityDefinition{
+			name:         activity.Name,
+			activityType: activity.Type,
+		}
+		for _, dependency := range activity.DependsOn {
+			definition.dependsOn = append(definition.dependsOn, dependency.Activity)
+		}
+		if timeout, ok := activity.Policy.Timeout.(string); ok {
+			definition.timeout = timeout
+		}
+		if notebookPath, ok := activity.TypeProperties.NotebookPath.(string); ok {
+			definition.notebookPath = notebookPath
+		}
+		activities = append(activities, definition)
+	}
+	return activities
+}
+
+// orderActivities sorts activities so every activity comes after the ones it
+// depends on, keeping definition order otherwise.
+func orderActivities(activities []activityDefinition) []activityDefinition {
+	ordered := []activityDefinition{}
+	placed := map[string]bool{}
+
+	for len(ordered) < len(activities) {
+		progressed := false
+		for _, activity := range activities {
+			if placed[activity.name] {
+				continue
+			}
+			ready := true
+			for _, dependency := range activity.dependsOn {
+				if !placed[dependency] {
+					ready = false
+				}
+			}
+			if ready {
+				ordered = append(ordered, activity)
+				placed[activity.name] = true
+				progressed = true
+			}
+		}
+
+		// dependency cycle or missing activity, keep the rest as defined
+		if !progressed {
+			for _, activity := range activities {
+				if !placed[activity.name] {
+					ordered = append(ordered, activity)
+					placed[activity.name] = true
+				}
+			}
+		}
+	}
+	return ordered
+}
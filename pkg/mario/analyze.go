@@ -1,38 +1,119 @@
 package mario
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"log"
 	"math"
 	"slices"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/datafactory/armdatafactory/v3"
 	"github.com/fatih/color"
+	"github.com/rodaine/table"
 )
 
 type RunStats struct {
-	pipelineName   string
-	pipelineResult string
-	startTime      time.Time
-	endTime        time.Time
-	durationMs     int32
+	FactoryName  string    `json:"factoryName" yaml:"factoryName"`
+	Profile      string    `json:"profile,omitempty" yaml:"profile,omitempty"`
+	RunID        string    `json:"runId" yaml:"runId"`
+	PipelineName string    `json:"pipelineName" yaml:"pipelineName"`
+	Status       string    `json:"status" yaml:"status"`
+	StartTime    time.Time `json:"startTime" yaml:"startTime"`
+	EndTime      time.Time `json:"endTime" yaml:"endTime"`
+	DurationMs   int32     `json:"durationMs" yaml:"durationMs"`
+	ZScore       float64   `json:"zScore" yaml:"zScore"`
+	Anomaly      bool      `json:"anomaly" yaml:"anomaly"`
+
+	// index of the factory among those read, which tells apart factories
+	// with the same name
+	factory int
+}
+
+type factoryRunStats struct {
+	runStats      []RunStats
+	durations     []int32
+	activityTrees map[string][]*activityNode
+	err           error
 }
 
-func AnalyzeRuns(nDays int, name string) {
+func AnalyzeRuns(query RunQuery, name string, drill bool) {
 	defer timer("AnalyzeRuns")()
-	factory := getFactoryClient()
+	factories := getFactoryClients()
 	ctx := context.Background()
 
-	pipelineRuns, _ := getPipelineRuns(&factory, ctx, nDays, name)
-	runStats, durations := collectPipelineRunStats(pipelineRuns)
-	printTimeseries(name, runStats, durations)
+	factoryStats := queryFactories(factories, func(factory *Factory) factoryRunStats {
+		pipelineRuns, err := fetchPipelineRuns(factory, ctx, query, name)
+		if err != nil {
+			return factoryRunStats{err: err}
+		}
+		runStats, durations := collectPipelineRunStats(pipelineRuns)
+		for i := range runStats {
+			runStats[i].FactoryName = factory.factoryName
+			runStats[i].Profile = factory.profile
+		}
+
+		// activity trees keyed by run id, only collected when drilling down
+		var activityTrees map[string][]*activityNode
+		if drill {
+			activityTrees = make(map[string][]*activityNode)
+			definitions := activityDefinitions{}
+			for _, run := range pipelineRuns.Value {
+				activityTrees[*run.RunID] = getActivityTree(factory, ctx, *run, definitions)
+			}
+		}
+		return factoryRunStats{runStats, durations, activityTrees, nil}
+	})
+
+	errs := []error{}
+	runStats := []RunStats{}
+	durations := []int32{}
+	activityTrees := make(map[string][]*activityNode)
+	for i, stats := range factoryStats {
+		errs = append(errs, stats.err)
+		for _, run := range stats.runStats {
+			run.factory = i
+			runStats = append(runStats, run)
+		}
+		durations = append(durations, stats.durations...)
+		for runID, tree := range stats.activityTrees {
+			activityTrees[runID] = tree
+		}
+	}
+	skipFailedFactories(factories, errs)
+
+	byFactory := len(factories) > 1
+	if byFactory {
+		slices.SortStableFunc(runStats, func(a, b RunStats) int {
+			return a.StartTime.Compare(b.StartTime)
+		})
+	}
+
+	baselines := scoreRuns(runStats, defaultAnomalyThreshold)
+
+	if !tableOutput() {
+		if err := writeRecords(runStats); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	printTimeseries(factories, name, runStats, durations, baselines, activityTrees)
 
 }
 
-func printTimeseries(name string, runStats []RunStats, durations []int32) {
+func printTimeseries(
+	factories []Factory,
+	name string,
+	runStats []RunStats,
+	durations []int32,
+	baselines map[string]durationBaseline,
+	activityTrees map[string][]*activityNode,
+) {
 	defer timer("printTimeseries")()
+	byFactory := len(factories) > 1
 	var (
 		barCharacter       = "\u25A4"
 		maxBarLength int32 = 40
@@ -61,21 +142,27 @@ func printTimeseries(name string, runStats []RunStats, durations []int32) {
 	maxDuration := slices.Max(durations)
 
 	color.New(color.Underline).Println(name)
+
+	factoryWidth := 0
+	for _, factory := range factories {
+		factoryWidth = max(factoryWidth, utf8.RuneCountInString(factory.label()))
+	}
+	printBaselines(factories, runStats, baselines, factoryWidth)
 	fmt.Println()
 
-	for i, run := range runStats {
+	for _, run := range runStats {
 		var (
-			duration     = run.durationMs
+			duration     = run.DurationMs
 			runDistance  = duration - minDuration
 			barDistance  = maxDuration - minDuration
 			durationTime = time.Duration(duration) * time.Millisecond
 
-			startTimeFormatted = run.startTime.Format("2006-01-02 15:04:05")
+			startTimeFormatted = run.StartTime.Format("2006-01-02 15:04:05")
 			durationFormatted  = durationTime.Truncate(time.Second).String()
 
-			previousDuration int32   = 0
-			pctDiff          float64 = 0
-			pctDiffFormatted string  = "0"
+			baseline         = baselines[baselineKey(run)]
+			pctDiff          = baseline.pctFromMedian(float64(duration))
+			pctDiffFormatted = fmt.Sprintf("%.2f", math.Abs(pctDiff))
 		)
 
 		barLengthFloat := float64(
@@ -97,12 +184,8 @@ func printTimeseries(name string, runStats []RunStats, durations []int32) {
 
 		bar := strings.Repeat(barCharacter, int(barLength))
 
-		if i > 0 {
-			previousDuration = int32(runStats[i-1].durationMs)
-			pctDiff = float64(duration-previousDuration) / float64(previousDuration) * 100
-			pctDiffFormatted = fmt.Sprintf("%.2f", math.Abs(pctDiff))
-		}
-
+		// runs are compared with the median rather than the previous run,
+		// so one slow run does not make the next look like an improvement
 		switch {
 		case pctDiff > 0:
 			pctDiffFormatted = failureColor()("\u2191", pctDiffFormatted, "%")
@@ -113,40 +196,198 @@ func printTimeseries(name string, runStats []RunStats, durations []int32) {
 		}
 
 		switch {
-		case run.pipelineResult == "Succeeded":
+		case run.Status == "Succeeded":
 			bar = successColor()(bar)
-		case run.pipelineResult == "Failed":
+		case run.Status == "Failed":
 			bar = failureColor()(bar)
-		case run.pipelineResult == "Cancelled":
+		case run.Status == "Cancelled":
 			bar = color.New(color.FgYellow).Sprint(bar)
 		default:
 			bar = neutralColor()(bar)
 		}
 
-		fmt.Println(startTimeFormatted, bar, durationFormatted, pctDiffFormatted)
+		if byFactory {
+			factoryFormatted := color.New(color.FgYellow).Sprintf(
+				"%-*s",
+				factoryWidth,
+				factories[run.factory].label(),
+			)
+			fmt.Print(factoryFormatted, " ")
+		}
+		if run.Anomaly {
+			fmt.Println(startTimeFormatted, bar, durationFormatted, pctDiffFormatted, anomalyFlag(run.ZScore))
+		} else {
+			fmt.Println(startTimeFormatted, bar, durationFormatted, pctDiffFormatted)
+		}
 
+		if tree, exists := activityTrees[run.RunID]; exists {
+			printActivityTreeCompact(tree, strings.Repeat(" ", 4))
+		}
+
+	}
+
+	if byFactory {
+		fmt.Println()
+		printFactoryRunSubtotals(factories, runStats)
 	}
 
 	fmt.Println(footer)
 
 }
 
+// printBaselines prints the median and MAD each run is compared with, and
+// how many runs are outliers.
+func printBaselines(
+	factories []Factory,
+	runStats []RunStats,
+	baselines map[string]durationBaseline,
+	factoryWidth int,
+) {
+	keys := []string{}
+	anomalies := map[string]int{}
+	firstRuns := map[string]RunStats{}
+	for _, run := range runStats {
+		key := baselineKey(run)
+		if _, exists := firstRuns[key]; !exists {
+			keys = append(keys, key)
+			firstRuns[key] = run
+		}
+		if run.Anomaly {
+			anomalies[key]++
+		}
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Or(
+			cmp.Compare(firstRuns[a].factory, firstRuns[b].factory),
+			strings.Compare(firstRuns[a].PipelineName, firstRuns[b].PipelineName),
+		)
+	})
+
+	for _, key := range keys {
+		baseline := baselines[key]
+		if len(factories) > 1 {
+			label := factories[firstRuns[key].factory].label()
+			fmt.Print(color.New(color.FgYellow).Sprintf("%-*s", factoryWidth, label), " ")
+		}
+		if baseline.runs < minBaselineRuns {
+			fmt.Println(neutralColor()(fmt.Sprintf(
+				"%d runs, too few to flag outliers",
+				baseline.runs,
+			)))
+			continue
+		}
+		fmt.Println(neutralColor()(fmt.Sprintf(
+			"median %s  mad %s  %d outliers",
+			formatMs(baseline.median),
+			formatMs(baseline.mad),
+			anomalies[key],
+		)))
+	}
+}
+
+// anomalyFlag marks an outlier run in the timeseries with its z-score.
+func anomalyFlag(zScore float64) string {
+	if zScore > 0 {
+		return failureColor()(fmt.Sprintf("\u26A0 slow z=%.1f", zScore))
+	}
+	return color.New(color.FgYellow).Sprintf("\u26A0 fast z=%.1f", zScore)
+}
+
+func formatMs(ms float64) string {
+	return (time.Duration(ms) * time.Millisecond).Truncate(time.Second).String()
+}
+
+// baselineKey identifies the runs that share a baseline: those of one
+// pipeline in one factory.
+func baselineKey(run RunStats) string {
+	return fmt.Sprintf("%d/%s", run.factory, run.PipelineName)
+}
+
+// scoreRuns sets the z-score of every run against the baseline of its
+// pipeline and flags those beyond threshold. Baselines are built from
+// successful runs, since failures often stop early, unless too few runs
+// succeeded.
+func scoreRuns(runStats []RunStats, threshold float64) map[string]durationBaseline {
+	succeeded := map[string][]float64{}
+	completed := map[string][]float64{}
+	for _, run := range runStats {
+		key := baselineKey(run)
+		completed[key] = append(completed[key], float64(run.DurationMs))
+		if run.Status == "Succeeded" {
+			succeeded[key] = append(succeeded[key], float64(run.DurationMs))
+		}
+	}
+
+	baselines := map[string]durationBaseline{}
+	for key, durations := range completed {
+		if len(succeeded[key]) >= minBaselineRuns {
+			durations = succeeded[key]
+		}
+		baselines[key] = newDurationBaseline(durations)
+	}
+
+	for i, run := range runStats {
+		zScore := baselines[baselineKey(run)].zScore(float64(run.DurationMs))
+		runStats[i].ZScore = math.Round(zScore*100) / 100
+		runStats[i].Anomaly = math.Abs(zScore) > threshold
+	}
+	return baselines
+}
+
+// printFactoryRunSubtotals prints the run count, failures and average
+// duration of each factory under a timeseries that mixes factories.
+func printFactoryRunSubtotals(factories []Factory, runStats []RunStats) {
+	headerFmt := color.New(color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+	tbl := table.New("Factory", "Runs", "\u2718", "Avg Duration")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	runsByFactory := make([][]RunStats, len(factories))
+	for _, run := range runStats {
+		runsByFactory[run.factory] = append(runsByFactory[run.factory], run)
+	}
+
+	for i, runs := range runsByFactory {
+		if len(runs) == 0 {
+			continue
+		}
+		failed := 0
+		var totalMs int64
+		for _, run := range runs {
+			totalMs += int64(run.DurationMs)
+			if run.Status == "Failed" {
+				failed++
+			}
+		}
+		avgDuration := time.Duration(totalMs/int64(len(runs))) * time.Millisecond
+		tbl.AddRow(factories[i].label(), len(runs), failed, avgDuration.Truncate(time.Second).String())
+	}
+	tbl.Print()
+}
+
 func collectPipelineRunStats(
-	pipelineRuns armdatafactory.PipelineRunsClientQueryByFactoryResponse,
+	pipelineRuns armdatafactory.PipelineRunsQueryResponse,
 ) ([]RunStats, []int32) {
 	defer timer("collectPipelineRunStats")()
 
-	runStats := make([]RunStats, len(pipelineRuns.Value))
-	durations := make([]int32, len(pipelineRuns.Value))
-	for i, run := range pipelineRuns.Value {
-		runStats[i] = RunStats{
-			pipelineName:   *run.PipelineName,
-			pipelineResult: *run.Status,
-			startTime:      *run.RunStart,
-			endTime:        *run.RunEnd,
-			durationMs:     *run.DurationInMs,
-		}
-		durations[i] = *run.DurationInMs
+	runStats := make([]RunStats, 0, len(pipelineRuns.Value))
+	durations := make([]int32, 0, len(pipelineRuns.Value))
+	for _, run := range pipelineRuns.Value {
+		// queued and in progress runs have no end time or duration yet
+		if run.RunEnd == nil || run.DurationInMs == nil {
+			continue
+		}
+
+		runStats = append(runStats, RunStats{
+			RunID:        *run.RunID,
+			PipelineName: *run.PipelineName,
+			Status:       *run.Status,
+			StartTime:    *run.RunStart,
+			EndTime:      *run.RunEnd,
+			DurationMs:   *run.DurationInMs,
+		})
+		durations = append(durations, *run.DurationInMs)
 
 	}
 
@@ -0,0 +1,152 @@
+package mario
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// pipelineRunStats returns a run with each duration, with run ids like
+// mario_job-Succeeded-0.
+func pipelineRunStats(pipeline string, status string, durations ...int32) []RunStats {
+	runs := []RunStats{}
+	for i, duration := range durations {
+		runs = append(runs, RunStats{
+			FactoryName:  "mario-fixtures",
+			RunID:        fmt.Sprintf("%s-%s-%d", pipeline, status, i),
+			PipelineName: pipeline,
+			Status:       status,
+			DurationMs:   duration,
+		})
+	}
+	return runs
+}
+
+// inFactory moves runs to the factory at index factory.
+func inFactory(factory int, runs []RunStats) []RunStats {
+	for i := range runs {
+		runs[i].factory = factory
+	}
+	return runs
+}
+
+func TestScoreRuns(t *testing.T) {
+	tests := []struct {
+		name          string
+		runs          []RunStats
+		threshold     float64
+		wantAnomalies []string
+	}{
+		{
+			name:          "steady",
+			runs:          pipelineRunStats("mario_job", "Succeeded", 100, 102, 98, 101, 99, 103),
+			threshold:     defaultAnomalyThreshold,
+			wantAnomalies: []string{},
+		},
+		{
+			name:          "slow run",
+			runs:          pipelineRunStats("mario_job", "Succeeded", 100, 102, 98, 101, 99, 400),
+			threshold:     defaultAnomalyThreshold,
+			wantAnomalies: []string{"mario_job-Succeeded-5"},
+		},
+		{
+			name:          "fast run",
+			runs:          pipelineRunStats("mario_job", "Succeeded", 100, 102, 98, 101, 99, 20),
+			threshold:     defaultAnomalyThreshold,
+			wantAnomalies: []string{"mario_job-Succeeded-5"},
+		},
+		{
+			name:          "lower threshold",
+			runs:          pipelineRunStats("mario_job", "Succeeded", 100, 104, 96, 102, 98, 110),
+			threshold:     2,
+			wantAnomalies: []string{"mario_job-Succeeded-5"},
+		},
+		{
+			name:          "too few runs for a baseline",
+			runs:          pipelineRunStats("mario_job", "Succeeded", 100, 102, 400),
+			threshold:     defaultAnomalyThreshold,
+			wantAnomalies: []string{},
+		},
+		{
+			name: "failures scored against successful runs",
+			runs: slices.Concat(
+				pipelineRunStats("mario_job", "Succeeded", 100, 102, 98, 101, 99),
+				pipelineRunStats("mario_job", "Failed", 10, 12, 11, 9, 10, 10),
+			),
+			threshold: defaultAnomalyThreshold,
+			wantAnomalies: []string{
+				"mario_job-Failed-0",
+				"mario_job-Failed-1",
+				"mario_job-Failed-2",
+				"mario_job-Failed-3",
+				"mario_job-Failed-4",
+				"mario_job-Failed-5",
+			},
+		},
+		{
+			name: "every completed run when too few succeeded",
+			runs: slices.Concat(
+				pipelineRunStats("mario_job", "Succeeded", 100, 102, 98),
+				pipelineRunStats("mario_job", "Failed", 101, 99, 400),
+			),
+			threshold:     defaultAnomalyThreshold,
+			wantAnomalies: []string{"mario_job-Failed-2"},
+		},
+		{
+			name: "pipelines have their own baselines",
+			runs: slices.Concat(
+				pipelineRunStats("mario_job", "Succeeded", 100, 102, 98, 101, 99),
+				pipelineRunStats("copy_iris_data", "Succeeded", 1000, 1020, 980, 1010, 990),
+			),
+			threshold:     defaultAnomalyThreshold,
+			wantAnomalies: []string{},
+		},
+		{
+			name: "factories with the same name have their own baselines",
+			runs: slices.Concat(
+				pipelineRunStats("mario_job", "Succeeded", 100, 102, 98, 101, 99, 100, 102, 98),
+				inFactory(1, pipelineRunStats("mario_job", "Succeeded", 1000, 1020, 980, 1010, 990)),
+			),
+			threshold:     defaultAnomalyThreshold,
+			wantAnomalies: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scoreRuns(test.runs, test.threshold)
+
+			anomalies := []string{}
+			for _, run := range test.runs {
+				if run.Anomaly {
+					anomalies = append(anomalies, run.RunID)
+				}
+			}
+			if !slices.Equal(anomalies, test.wantAnomalies) {
+				t.Errorf("anomalies = %v, want %v", anomalies, test.wantAnomalies)
+			}
+		})
+	}
+}
+
+func TestDurationBaselineZScore(t *testing.T) {
+	baseline := newDurationBaseline([]float64{98, 99, 100, 101, 102})
+
+	tests := []struct {
+		duration float64
+		want     float64
+	}{
+		{duration: 100, want: 0},
+		{duration: 101, want: 0.6745},
+		{duration: 90, want: -6.745},
+	}
+	for _, test := range tests {
+		if got := baseline.zScore(test.duration); fmt.Sprintf("%.4f", got) != fmt.Sprintf("%.4f", test.want) {
+			t.Errorf("zScore(%v) = %v, want %v", test.duration, got, test.want)
+		}
+	}
+
+	if got := newDurationBaseline([]float64{100, 100, 100, 100, 100}).zScore(500); got != 0 {
+		t.Errorf("zScore without spread = %v, want 0", got)
+	}
+}
@@ -0,0 +1,155 @@
+package mario
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+)
+
+// RunAnomaly is a run whose duration is far from the median of its
+// pipeline's runs in the window.
+type RunAnomaly struct {
+	FactoryName   string    `json:"factoryName" yaml:"factoryName"`
+	PipelineName  string    `json:"pipelineName" yaml:"pipelineName"`
+	RunID         string    `json:"runId" yaml:"runId"`
+	Status        string    `json:"status" yaml:"status"`
+	StartTime     time.Time `json:"startTime" yaml:"startTime"`
+	DurationMs    int32     `json:"durationMs" yaml:"durationMs"`
+	MedianMs      int64     `json:"medianMs" yaml:"medianMs"`
+	PctFromMedian float64   `json:"pctFromMedian" yaml:"pctFromMedian"`
+	ZScore        float64   `json:"zScore" yaml:"zScore"`
+}
+
+// AnalyzeAnomalies lists the runs of every pipeline matching name whose
+// robust z-score is beyond threshold, most severe first.
+func AnalyzeAnomalies(query RunQuery, name string, threshold float64) {
+	defer timer("AnalyzeAnomalies")()
+	factories := getFactoryClients()
+	ctx := context.Background()
+
+	factoryStats := queryFactories(factories, func(factory *Factory) []RunStats {
+		pipelineRuns, _ := getPipelineRuns(factory, ctx, query, "")
+		runStats, _ := collectPipelineRunStats(pipelineRuns)
+
+		matching := []RunStats{}
+		for _, run := range runStats {
+			if strings.Contains(run.PipelineName, name) {
+				run.FactoryName = factory.factoryName
+				matching = append(matching, run)
+			}
+		}
+		return matching
+	})
+
+	runStats := []RunStats{}
+	for i, stats := range factoryStats {
+		for _, run := range stats {
+			run.factory = i
+			runStats = append(runStats, run)
+		}
+	}
+	baselines := scoreRuns(runStats, threshold)
+
+	anomalies := []RunAnomaly{}
+	for _, run := range runStats {
+		if !run.Anomaly {
+			continue
+		}
+		baseline := baselines[baselineKey(run)]
+		anomalies = append(anomalies, RunAnomaly{
+			FactoryName:   run.FactoryName,
+			PipelineName:  run.PipelineName,
+			RunID:         run.RunID,
+			Status:        run.Status,
+			StartTime:     run.StartTime,
+			DurationMs:    run.DurationMs,
+			MedianMs:      int64(baseline.median),
+			PctFromMedian: math.Round(baseline.pctFromMedian(float64(run.DurationMs))*100) / 100,
+			ZScore:        run.ZScore,
+		})
+	}
+	slices.SortStableFunc(anomalies, func(a, b RunAnomaly) int {
+		return cmp.Or(
+			cmp.Compare(math.Abs(b.ZScore), math.Abs(a.ZScore)),
+			b.StartTime.Compare(a.StartTime),
+		)
+	})
+
+	if !tableOutput() {
+		if err := writeRecords(anomalies); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	printAnomalies(anomalies, len(runStats), threshold, len(factories) > 1)
+}
+
+func printAnomalies(anomalies []RunAnomaly, runs int, threshold float64, byFactory bool) {
+	defer timer("printAnomalies")()
+	headerLength := 80
+
+	header := createHeader(
+		"ANOMALIES",
+		headerLength,
+		color.New(color.FgBlue),
+		"=",
+		true,
+	)
+	footer := createHeader("", headerLength, color.New(color.FgWhite), "=", true)
+
+	fmt.Print("\n", header, "\n")
+	fmt.Println(neutralColor()(fmt.Sprintf(
+		"%d of %d runs are more than %.1f robust z-scores from their pipeline's median",
+		len(anomalies),
+		runs,
+		threshold,
+	)))
+	fmt.Println()
+
+	if len(anomalies) == 0 {
+		fmt.Println(successColor()("No anomalous runs found"))
+		fmt.Println(footer)
+		return
+	}
+
+	headerFmt := color.New(color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+	columns := []interface{}{"Pipeline", "Start", "Duration", "Median", "Change", "Z", "Status"}
+	if byFactory {
+		columns = append([]interface{}{"Factory"}, columns...)
+	}
+	tbl := table.New(columns...)
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	for _, anomaly := range anomalies {
+		change := successColor()(fmt.Sprintf("↓%.2f%%", math.Abs(anomaly.PctFromMedian)))
+		if anomaly.PctFromMedian > 0 {
+			change = failureColor()(fmt.Sprintf("↑%.2f%%", anomaly.PctFromMedian))
+		}
+
+		row := []interface{}{
+			anomaly.PipelineName,
+			anomaly.StartTime.Format("2006-01-02 15:04:05"),
+			formatMs(float64(anomaly.DurationMs)),
+			formatMs(float64(anomaly.MedianMs)),
+			change,
+			fmt.Sprintf("%.1f", anomaly.ZScore),
+			anomaly.Status,
+		}
+		if byFactory {
+			row = append([]interface{}{anomaly.FactoryName}, row...)
+		}
+		tbl.AddRow(row...)
+	}
+	tbl.Print()
+	fmt.Println(footer)
+}
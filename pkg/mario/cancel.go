@@ -0,0 +1,149 @@
+package mario
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/datafactory/armdatafactory/v3"
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+)
+
+// CancelOptions picks the runs to cancel. Either RunID names a single run, or
+// every run of a pipeline matching Name in one of Statuses is cancelled.
+// Recursive also cancels the child pipelines started by Execute Pipeline
+// activities.
+type CancelOptions struct {
+	RunID     string
+	Name      string
+	Statuses  []string
+	Query     RunQuery
+	Recursive bool
+	Yes       bool
+}
+
+func Cancel(options CancelOptions) {
+	defer timer("Cancel")()
+	factory := getFactoryClient()
+	ctx := context.Background()
+
+	runs := []*armdatafactory.PipelineRun{}
+	if options.RunID != "" {
+		run, err := factory.source.GetPipelineRun(ctx, options.RunID)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !isRunActive(stringValue(run.Status)) {
+			fmt.Printf("Run %s is already %s\n", options.RunID, stringValue(run.Status))
+			return
+		}
+		runs = append(runs, &run)
+	} else {
+		runs = runsToCancel(&factory, ctx, options)
+	}
+
+	if len(runs) == 0 {
+		fmt.Println("No runs to cancel")
+		return
+	}
+
+	errs := make([]error, len(runs))
+	printCancelRuns(runs, errs, false)
+
+	prompt := fmt.Sprintf("Cancel %d run(s)?", len(runs))
+	if options.Recursive {
+		prompt = fmt.Sprintf("Cancel %d run(s) and their child pipelines?", len(runs))
+	}
+	if !options.Yes && !confirm(prompt) {
+		fmt.Println("aborted")
+		return
+	}
+
+	failures := 0
+	for i, run := range runs {
+		errs[i] = factory.source.CancelRun(ctx, stringValue(run.RunID), options.Recursive)
+		if errs[i] != nil {
+			failures++
+		}
+	}
+
+	fmt.Println()
+	printCancelRuns(runs, errs, true)
+	if failures > 0 {
+		log.Fatalf("%d of %d runs could not be cancelled", failures, len(runs))
+	}
+}
+
+func runsToCancel(
+	factory *Factory,
+	ctx context.Context,
+	options CancelOptions,
+) []*armdatafactory.PipelineRun {
+	query := options.Query
+	query.Statuses = options.Statuses
+
+	pipelineRuns, err := getPipelineRuns(factory, ctx, query, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	runs := []*armdatafactory.PipelineRun{}
+	for _, run := range pipelineRuns.Value {
+		// cancelling a finished run is a no-op, so never include one even if
+		// it was asked for by status
+		if !isRunActive(stringValue(run.Status)) {
+			continue
+		}
+		if strings.Contains(stringValue(run.PipelineName), options.Name) {
+			runs = append(runs, run)
+		}
+	}
+
+	slices.SortFunc(runs, func(a, b *armdatafactory.PipelineRun) int {
+		return compareTimes(a.RunStart, b.RunStart)
+	})
+	return runs
+}
+
+// printCancelRuns lists the affected runs before confirmation, and the outcome
+// of each cancel request afterwards.
+func printCancelRuns(runs []*armdatafactory.PipelineRun, errs []error, withResult bool) {
+	headerFmt := color.New(color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+	columns := []interface{}{"Pipeline", "Run ID", "Status", "Started", "Elapsed"}
+	if withResult {
+		columns = append(columns, "Result")
+	}
+	tbl := table.New(columns...)
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	for i, run := range runs {
+		elapsed := ""
+		if run.RunStart != nil {
+			elapsed = time.Since(*run.RunStart).Truncate(time.Second).String()
+		}
+
+		status := stringValue(run.Status)
+		row := []interface{}{
+			stringValue(run.PipelineName),
+			stringValue(run.RunID),
+			statusColor(status)(status),
+			formatRunTime(run.RunStart),
+			elapsed,
+		}
+		if withResult {
+			result := successColor()("cancel requested")
+			if errs[i] != nil {
+				result = failureColor()(errs[i].Error())
+			}
+			row = append(row, result)
+		}
+		tbl.AddRow(row...)
+	}
+	tbl.Print()
+}
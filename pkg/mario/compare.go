@@ -3,66 +3,122 @@ package mario
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/datafactory/armdatafactory/v3"
 	"github.com/fatih/color"
-	"github.com/go-test/deep"
 )
 
-func Compare(name1 string, name2 string) {
+// comparedKeysToDrop are the top level keys that always differ between
+// copies of the same resource and are left out of comparisons.
+var comparedKeysToDrop = []string{"id", "etag", "name", "type"}
+
+// PipelineRef is one side of a comparison: a deployed pipeline by name, or a
+// pipeline definition in a local JSON file like those upload_pipeline.py
+// deploys.
+type PipelineRef struct {
+	Name string
+	File string
+}
+
+func (ref PipelineRef) String() string {
+	if ref.File != "" {
+		return ref.File
+	}
+	return ref.Name
+}
+
+// Compare prints the differences between two pipelines and reports whether
+// there were any. Errors, such as a missing pipeline, are returned rather
+// than exiting so callers can tell them apart from differences.
+func Compare(ref1 PipelineRef, ref2 PipelineRef) (bool, error) {
 	defer timer("Compare")()
-	factory := getFactoryClient()
 	ctx := context.Background()
-	wg := sync.WaitGroup{}
-	wg.Add(2)
 
-	pipelineChan := make(chan armdatafactory.PipelinesClientGetResponse, 2)
-	go getPipeline(name1, factory, ctx, pipelineChan, &wg)
-	go getPipeline(name2, factory, ctx, pipelineChan, &wg)
+	// file against file needs no factory
+	var factory Factory
+	if ref1.File == "" || ref2.File == "" {
+		var err error
+		factory, err = factoryClient()
+		if err != nil {
+			return false, err
+		}
+	}
 
+	// results are indexed by ref so pipeline1 is always ref1, whichever
+	// request finishes first
+	refs := []PipelineRef{ref1, ref2}
+	pipelines := make([]armdatafactory.PipelineResource, len(refs))
+	errs := make([]error, len(refs))
+	wg := sync.WaitGroup{}
+	for i, ref := range refs {
+		wg.Add(1)
+		go func(i int, ref PipelineRef) {
+			defer wg.Done()
+			pipelines[i], errs[i] = getComparedPipeline(ctx, ref, factory)
+		}(i, ref)
+	}
 	wg.Wait()
-	close(pipelineChan)
+	if err := errors.Join(errs...); err != nil {
+		return false, err
+	}
 
-	pipeline1 := <-pipelineChan
-	pipeline2 := <-pipelineChan
+	name1 := ref1.String()
+	name2 := ref2.String()
 
-	pipeline1Map := parsePipeline(
-		pipeline1,
-		[]string{"id", "etag", "name", "type"},
-	)
-	pipeline2Map := parsePipeline(
-		pipeline2,
-		[]string{"id", "etag", "name", "type"},
-	)
+	pipeline1Map := parsePipeline(pipelines[0], comparedKeysToDrop)
+	pipeline2Map := parsePipeline(pipelines[1], comparedKeysToDrop)
 
-	// try out different equality checks
-	diffRaw := deep.Equal(pipeline1Map, pipeline2Map)
-	differencesExist := diffRaw != nil
-
-	var diff []string
-	if differencesExist {
-		diff = formatDiff(
-			diffRaw,
-			[]string{"properties", "activities", "slice"},
-		)
-	} else {
-		diff = []string{"No differences found"}
+	rules, err := loadIgnoreRules()
+	if err != nil {
+		return false, err
 	}
+	diff, resource1, resource2 := diffWithoutIgnored(pipeline1Map, pipeline2Map, rules)
+
+	switch {
+	case reportFormat != "":
+		err = writePipelineReport(diff, resource1, resource2, ref1, ref2)
+	case !tableOutput():
+		comparison := PipelineComparison{
+			Pipeline1:    name1,
+			Pipeline2:    name2,
+			Equal:        diff.equal(),
+			ResourceDiff: diff,
+		}
+		err = writeOutput(comparison, diff.records())
+	default:
+		printDiffOutput(diff, resource1, resource2, name1, name2)
+	}
+	return !diff.equal(), err
+}
+
+// PipelineComparison is the result of Compare for the non-table outputs.
+// Value1 is the value in Pipeline1 and Value2 the value in Pipeline2.
+type PipelineComparison struct {
+	Pipeline1    string `json:"pipeline1" yaml:"pipeline1"`
+	Pipeline2    string `json:"pipeline2" yaml:"pipeline2"`
+	Equal        bool   `json:"equal" yaml:"equal"`
+	ResourceDiff `yaml:",inline"`
+}
 
-	printDiffOutput(diff, differencesExist, name1, name2)
+type PipelineDifference struct {
+	Path   string `json:"path" yaml:"path"`
+	Value1 string `json:"value1" yaml:"value1"`
+	Value2 string `json:"value2" yaml:"value2"`
 }
 
+// printDiffOutput prints a comparison in the selected diff format. The
+// unified and side-by-side formats show resource1 and resource2, which should
+// already be pruned of ignored values.
 func printDiffOutput(
-	diff []string,
-	differencesExist bool,
+	diff ResourceDiff,
+	resource1 map[string]interface{},
+	resource2 map[string]interface{},
 	name1 string,
 	name2 string,
 ) {
@@ -91,7 +147,7 @@ func printDiffOutput(
 
 	fmt.Print("\n", header, "\n")
 
-	if differencesExist {
+	if !diff.equal() {
 		fmt.Println(
 			"[",
 			cross("\u2718"),
@@ -103,32 +159,42 @@ func printDiffOutput(
 	} else {
 		fmt.Println("[", check("\u2714"), "]", pipeline1Color(name1), "|", pipeline2Color(name2))
 		fmt.Println(check("No differences found"))
+		if len(diff.Ignored) > 0 {
+			fmt.Println(neutralColor()(ignoredSummary(diff.Ignored)))
+		}
 		fmt.Println(footer)
 		return
 	}
 
 	fmt.Print("\n", cross("Differences found"), "\n")
-	for i, d := range diff {
-		diffSplit := strings.Split(d, "\n")
-
-		location := diffSplit[:len(diffSplit)-1]
-		value := diffSplit[len(diffSplit)-1]
-		value = strings.Trim(value, " ")
 
-		valueSplit := strings.Split(value, "!=")
+	switch diffFormat {
+	case "unified":
+		printUnifiedDiff(resource1, resource2, name1, name2)
+	case "side-by-side":
+		printSideBySideDiff(resource1, resource2, name1, name2)
+	default:
+		printDiffSections(diff, pipeline1Color, pipeline2Color)
+	}
 
-		value1 := valueSplit[1]
-		value2 := valueSplit[0]
-		valueFormatted := pipeline1Color(
-			value1,
-		) + " != " + pipeline2Color(
-			value2,
-		)
+	if len(diff.Ignored) > 0 {
+		fmt.Println(neutralColor()(ignoredSummary(diff.Ignored)))
+	}
+	fmt.Println(footer)
+}
 
+func printDiffSections(
+	diff ResourceDiff,
+	pipeline1Color func(a ...interface{}) string,
+	pipeline2Color func(a ...interface{}) string,
+) {
+	headerLength := 80
+	sections := diffSections(diff, pipeline1Color, pipeline2Color)
+	for i, section := range sections {
 		diffMessage := "[" + strconv.Itoa(
 			i+1,
 		) + "/" + strconv.Itoa(
-			len(diff),
+			len(sections),
 		) + "]"
 
 		diffHeader := createHeader(
@@ -138,63 +204,103 @@ func printDiffOutput(
 			"-",
 			false,
 		)
-		fmt.Print(diffHeader)
-
-		fmt.Print("\n", strings.Join(location, "\n"))
-		fmt.Print(":", valueFormatted, "\n\n")
+		fmt.Print(diffHeader, "\n")
+		fmt.Print(section, "\n")
+	}
+}
 
+// diffSections renders each activity change and each other difference as
+// one section of text.
+func diffSections(
+	diff ResourceDiff,
+	value1Color func(a ...interface{}) string,
+	value2Color func(a ...interface{}) string,
+) []string {
+	sections := []string{}
+	for _, change := range diff.Activities {
+		sections = append(
+			sections,
+			describeActivityChange(change)+"\n"+
+				formatDifferences(change.Differences, "  ", value1Color, value2Color),
+		)
 	}
-	fmt.Println(footer)
+	for _, difference := range diff.Differences {
+		difference.Path = strings.TrimPrefix(difference.Path, "properties.")
+		sections = append(
+			sections,
+			formatDifferences([]PipelineDifference{difference}, "", value1Color, value2Color),
+		)
+	}
+	return sections
 }
 
-func formatDiff(diff []string, keysToExclude []string) []string {
-	formattedDiff := make([]string, len(diff))
-
-	for i, d := range diff {
-		diffSplit := strings.Split(d, ": ")
-		location := diffSplit[0]
-		locationParts := strings.Split(location, ".")
-
-		formattedPartsRaw := make([]string, len(locationParts))
-		// initial string cleaning
-		for i, part := range locationParts {
-			formattedPart := strings.Replace(part, "]", "", -1)
-			formattedPart = strings.Replace(formattedPart, "map[", "", -1)
-			formattedPart = strings.Replace(formattedPart, "[", "", -1)
-			formattedPartsRaw[i] = formattedPart
-		}
+func describeActivityChange(change ActivityChange) string {
+	activity := fmt.Sprintf("%s (%s)", change.Activity, change.Type)
+	switch change.Change {
+	case activityAdded:
+		return fmt.Sprintf("added activity %s at position %d", activity, change.Position2)
+	case activityRemoved:
+		return fmt.Sprintf("removed activity %s from position %d", activity, change.Position1)
+	case activityRenamed:
+		return fmt.Sprintf("renamed activity %s to %s", change.RenamedFrom, activity)
+	case activityReordered:
+		return fmt.Sprintf(
+			"moved activity %s from position %d to %d",
+			activity,
+			change.Position1,
+			change.Position2,
+		)
+	}
+	return fmt.Sprintf("modified activity %s", activity)
+}
 
-		// remove keys that we don't want to show
-		formattedParts := []string{}
-		for _, part := range formattedPartsRaw {
-			for _, key := range keysToExclude {
-				if strings.Contains(part, key) {
-					part = ""
-				}
-			}
-			if part == "" {
-				continue
-			}
-			formattedParts = append(formattedParts, part)
+// formatDifferences writes each path as nested keys. Keys shared with the
+// previous path are not repeated, so the differences of one property are
+// grouped under it.
+func formatDifferences(
+	differences []PipelineDifference,
+	indent string,
+	value1Color func(a ...interface{}) string,
+	value2Color func(a ...interface{}) string,
+) string {
+	lines := []string{}
+	previous := []string{}
+	for _, difference := range differences {
+		parts := strings.Split(difference.Path, ".")
+		last := len(parts) - 1
+
+		shared := 0
+		for shared < last && shared < len(previous)-1 && parts[shared] == previous[shared] {
+			shared++
 		}
-
-		// add indentation based on the depth of the key
-		for i, part := range formattedParts {
-			part = strings.Repeat(" ", i*2) + part + "\n"
-			formattedParts[i] = part
+		for depth := shared; depth < last; depth++ {
+			lines = append(lines, indent+strings.Repeat("  ", depth)+parts[depth])
 		}
+		lines = append(lines, fmt.Sprint(
+			indent+strings.Repeat("  ", last)+parts[last]+": ",
+			value1Color(separableValue(difference.Value1)),
+			" != ",
+			value2Color(separableValue(difference.Value2)),
+		))
+		previous = parts
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
 
-		formattedPartsString := strings.Join(formattedParts, "")
-
-		value := diffSplit[1]
-		formattedValue := strings.Repeat(" ", len(formattedParts)*2) + value
-		formattedDiff[i] = formattedPartsString + formattedValue
+// separableValue quotes a value containing the " != " that separates the
+// two sides of a difference, so the line still reads unambiguously.
+func separableValue(value string) string {
+	if strings.Contains(value, " != ") {
+		return strconv.Quote(value)
 	}
-	return formattedDiff
+	return value
 }
 
 func parsePipeline(
-	pipeline armdatafactory.PipelinesClientGetResponse,
+	pipeline armdatafactory.PipelineResource,
 	keysToDrop []string,
 ) map[string]interface{} {
 	pipelineJson, _ := pipeline.MarshalJSON()
@@ -219,78 +325,38 @@ func jsonToMap(jsonStr string) map[string]interface{} {
 	return result
 }
 
-func getPipelineHttp(
+func getComparedPipeline(
+	ctx context.Context,
+	ref PipelineRef,
 	factory Factory,
-	pipelineName string,
-	pipelineChan chan http.Response,
-	wg *sync.WaitGroup,
-) {
-	defer timer("getPipelineHttp")
-	defer wg.Done()
-	requestString := fmt.Sprintf(
-		"https://management.azure.com/subscriptions/%s/resourceGroups/%s/providers/Microsoft.DataFactory/factories/%s/pipelines/%s?api-version=2018-06-01",
-		factory.subscriptionID,
-		factory.resouceGroupName,
-		factory.factoryName,
-		pipelineName,
-	)
-	req, err := http.NewRequest("GET", requestString, nil)
-	if err != nil {
-		log.Fatal(err)
+) (armdatafactory.PipelineResource, error) {
+	if ref.File == "" {
+		return getPipeline(ctx, ref.Name, factory)
 	}
+	return readPipelineFile(ref.File)
+}
 
-	cred, err := azidentity.NewDefaultAzureCredential(nil)
-	if err != nil {
-		log.Fatal(err)
-	}
+// readPipelineFile reads a pipeline definition through the SDK type, so it
+// is normalized the same way as a deployed pipeline before comparing.
+func readPipelineFile(path string) (armdatafactory.PipelineResource, error) {
+	defer timer("readPipelineFile")()
+	pipeline := armdatafactory.PipelineResource{}
 
-	token, err := cred.GetToken(
-		context.TODO(),
-		policy.TokenRequestOptions{
-			Scopes: []string{"https://management.azure.com/.default"},
-		},
-	)
+	data, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatal(err)
+		return pipeline, err
 	}
-
-	req.Header.Set("Authorization", "Bearer "+token.Token)
-	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{}
-	resp, err := client.Do(req)
-
-	if err != nil {
-		log.Fatal(err)
+	if err := pipeline.UnmarshalJSON(data); err != nil {
+		return pipeline, fmt.Errorf("%s: %w", path, err)
 	}
-
-	fmt.Println(resp)
-
-	pipelineChan <- *resp
-
+	return pipeline, nil
 }
 
 func getPipeline(
+	ctx context.Context,
 	name string,
 	factory Factory,
-	ctx context.Context,
-	pipelineChan chan armdatafactory.PipelinesClientGetResponse,
-	wg *sync.WaitGroup,
-) {
+) (armdatafactory.PipelineResource, error) {
 	defer timer("getPipeline")()
-	defer wg.Done()
-
-	pipelineClient := factory.factoryClient.NewPipelinesClient()
-	pipeline, err := pipelineClient.Get(
-		ctx,
-		factory.resouceGroupName,
-		factory.factoryName,
-		name,
-		nil,
-	)
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	pipelineChan <- pipeline
+	return factory.source.GetPipeline(ctx, name)
 }
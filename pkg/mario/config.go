@@ -2,96 +2,472 @@ package mario
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/google/uuid"
+	"github.com/rodaine/table"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// project config, read from the current directory
+	configFile = ".mario.yaml"
+	// three positional lines written by earlier versions, read as the
+	// "default" profile until `mario setup` writes a configFile
+	legacyConfigFile = ".mariocfg"
+	defaultProfile   = "default"
 )
 
+// authMethods are the credentials a profile can ask for. default tries the
+// environment, managed identity and the Azure CLI in turn.
+var authMethods = []string{"default", "cli", "environment", "managed-identity"}
+
+var (
+	profileName       string
+	profileNameSource string
+	// shared so buffered input is not lost between prompts
+	stdinReader = bufio.NewReader(os.Stdin)
+)
+
+// AZEnv is one named profile: the factory mario talks to, how to
+// authenticate, and defaults for the run query flags.
 type AZEnv struct {
-	SubscriptionID    string
-	ResourceGroupName string
-	DataFactoryName   string
+	SubscriptionID    string          `yaml:"subscriptionId,omitempty"`
+	ResourceGroupName string          `yaml:"resourceGroup,omitempty"`
+	DataFactoryName   string          `yaml:"factory,omitempty"`
+	Auth              string          `yaml:"auth,omitempty"`
+	Defaults          ProfileDefaults `yaml:"defaults,omitempty"`
+}
+
+type ProfileDefaults struct {
+	Days     int    `yaml:"days,omitempty"`
+	Timezone string `yaml:"timezone,omitempty"`
+}
+
+// marioConfig is one config file. Groups name sets of profiles that commands
+// reading several factories can query together.
+type marioConfig struct {
+	Current  string              `yaml:"current,omitempty"`
+	Profiles map[string]AZEnv    `yaml:"profiles"`
+	Groups   map[string][]string `yaml:"groups,omitempty"`
+	Compare  CompareConfig       `yaml:"compare,omitempty"`
+	SLA      []PipelineSLA       `yaml:"sla,omitempty"`
+}
+
+// UseProfile selects a profile by name instead of the configs' current one.
+// source describes where the name came from, such as a flag or env var.
+func UseProfile(name string, source string) {
+	profileName = name
+	profileNameSource = source
+}
+
+// userConfigPath is the config shared by every directory, e.g.
+// ~/.config/mario/config.yaml on Linux.
+func userConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "mario", "config.yaml")
 }
 
-func ConfigSetup() {
+// configPath is the file setup and `config use` write to.
+func configPath(global bool) string {
+	if !global {
+		return configFile
+	}
+	path := userConfigPath()
+	if path == "" {
+		log.Fatal("no user config directory, set $HOME or $XDG_CONFIG_HOME")
+	}
+	return path
+}
+
+func ConfigSetup(global bool) {
+	path := configPath(global)
+	config, err := loadConfig(path)
+	if err != nil {
+		log.Fatal(err)
+	}
 
+	name := parseInput("Enter a name for this profile (default): ")
+	if name == "" {
+		name = defaultProfile
+	}
 	azSubscriptionID := parseInput("Enter your Azure Subscription ID: ")
 	azResourceGroupName := parseInput("Enter your Resource Group Name: ")
 	azDataFactoryName := parseInput("Enter your Data Factory Name: ")
+	auth := parseInput(
+		fmt.Sprintf("Enter an auth method, one of %s (default): ", strings.Join(authMethods, ", ")),
+	)
 
 	azEnv := AZEnv{
 		SubscriptionID:    azSubscriptionID,
 		ResourceGroupName: azResourceGroupName,
 		DataFactoryName:   azDataFactoryName,
+		Auth:              auth,
+	}
+	for _, problem := range validateProfile(azEnv) {
+		fmt.Println(failureColor()("warning:"), problem)
+	}
+
+	config.Profiles[name] = azEnv
+	if config.Current == "" {
+		config.Current = name
+	}
+	writeConfig(path, config)
+	fmt.Printf("Azure environment details added to profile %s in %s.\n", name, path)
+}
+
+// loadConfig reads one config file. A missing file is an empty config.
+func loadConfig(path string) (marioConfig, error) {
+	config := marioConfig{Profiles: map[string]AZEnv{}}
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && path == configFile {
+		return readLegacyConfig(config)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("%s: %w", path, err)
+	}
+	if config.Profiles == nil {
+		config.Profiles = map[string]AZEnv{}
+	}
+	return config, nil
+}
+
+func readLegacyConfig(config marioConfig) (marioConfig, error) {
+	data, err := os.ReadFile(legacyConfigFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+
+	lines := strings.Fields(string(data))
+	if len(lines) != 3 {
+		return config, fmt.Errorf(
+			"%s: expected subscription, resource group and factory on separate lines, run `mario setup` to replace it with %s",
+			legacyConfigFile,
+			configFile,
+		)
 	}
 
-	writeConfig(azEnv)
-	fmt.Println("Azure environment details added to the CLI.")
+	config.Current = defaultProfile
+	config.Profiles[defaultProfile] = AZEnv{
+		SubscriptionID:    lines[0],
+		ResourceGroupName: lines[1],
+		DataFactoryName:   lines[2],
+	}
+	return config, nil
 }
 
-func writeConfig(azEnv AZEnv) {
-	f, err := os.Create(".mariocfg")
+func writeConfig(path string, config marioConfig) {
+	data, err := yaml.Marshal(config)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer f.Close()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// errNoProfileSelected is returned when several profiles exist and none is
+// current or requested.
+var errNoProfileSelected = errors.New(
+	"no profile selected, pass --profile or run `mario config use <profile>`",
+)
+
+// selectProfile picks the requested profile, usually the one named by
+// --profile, then the config's current profile, then the only profile if
+// there is just one. An empty name with no error means no profile is
+// configured.
+func (config marioConfig) selectProfile(requested string) (string, AZEnv, error) {
+	name := requested
+	if name == "" {
+		name = config.Current
+	}
+	if name == "" && len(config.Profiles) == 1 {
+		for onlyProfile := range config.Profiles {
+			name = onlyProfile
+		}
+	}
 
-	_, err = f.WriteString(azEnv.SubscriptionID)
+	if name == "" && len(config.Profiles) == 0 {
+		return "", AZEnv{}, nil
+	}
+	if name == "" {
+		return "", AZEnv{}, errNoProfileSelected
+	}
+
+	azEnv, exists := config.Profiles[name]
+	if !exists {
+		return "", AZEnv{}, fmt.Errorf(
+			"profile %q not found, expected one of %s",
+			name,
+			strings.Join(config.profileNames(), ", "),
+		)
+	}
+	return name, azEnv, nil
+}
+
+func (config marioConfig) profileNames() []string {
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// GetProfileDefaults returns the resolved run query defaults, or no defaults
+// when there is no usable config.
+func GetProfileDefaults() ProfileDefaults {
+	azEnv, _, err := resolveAZEnv(profileName, nil)
+	if err != nil {
+		return ProfileDefaults{}
+	}
+	return azEnv.Defaults
+}
+
+// ProfileRecord is one profile of ListProfiles in the non-table outputs. A
+// profile defined in both config files has a record for each.
+type ProfileRecord struct {
+	Current       bool   `json:"current" yaml:"current"`
+	Profile       string `json:"profile" yaml:"profile"`
+	Subscription  string `json:"subscriptionId" yaml:"subscriptionId"`
+	ResourceGroup string `json:"resourceGroup" yaml:"resourceGroup"`
+	Factory       string `json:"factory" yaml:"factory"`
+	Auth          string `json:"auth" yaml:"auth"`
+	Source        string `json:"source" yaml:"source"`
+}
+
+func ListProfiles() {
+	layers, err := loadConfigLayers()
 	if err != nil {
 		log.Fatal(err)
 	}
-	_, err = f.WriteString(azEnv.ResourceGroupName)
+	current := mergeConfigLayers(layers).Current
+
+	records := []ProfileRecord{}
+	for _, layer := range layers {
+		for _, name := range layer.config.profileNames() {
+			azEnv := layer.config.Profiles[name]
+			records = append(records, ProfileRecord{
+				Current:       name == current,
+				Profile:       name,
+				Subscription:  azEnv.SubscriptionID,
+				ResourceGroup: azEnv.ResourceGroupName,
+				Factory:       azEnv.DataFactoryName,
+				Auth:          azEnv.Auth,
+				Source:        layer.source,
+			})
+		}
+	}
+
+	if !tableOutput() {
+		if err := writeRecords(records); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	headerFmt := color.New(color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+	tbl := table.New("", "Profile", "Subscription", "Resource Group", "Factory", "Auth", "Source")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	for _, record := range records {
+		marker := ""
+		if record.Current {
+			marker = "*"
+		}
+		tbl.AddRow(
+			marker,
+			record.Profile,
+			record.Subscription,
+			record.ResourceGroup,
+			record.Factory,
+			record.Auth,
+			record.Source,
+		)
+	}
+	tbl.Print()
+}
+
+// UseProfileByDefault makes a profile the one used when --profile is not set.
+func UseProfileByDefault(name string, global bool) {
+	layers, err := loadConfigLayers()
 	if err != nil {
 		log.Fatal(err)
 	}
-	_, err = f.WriteString(azEnv.DataFactoryName)
+	merged := mergeConfigLayers(layers)
+	if _, exists := merged.Profiles[name]; !exists {
+		log.Fatalf(
+			"profile %q not found, expected one of %s",
+			name,
+			strings.Join(merged.profileNames(), ", "),
+		)
+	}
+
+	path := configPath(global)
+	config, err := loadConfig(path)
 	if err != nil {
 		log.Fatal(err)
 	}
+	config.Current = name
+	writeConfig(path, config)
+	fmt.Printf("Now using profile %s\n", name)
 }
 
-func readConfig() AZEnv {
-	defer timer("readConfig")()
-	f, err := os.Open(".mariocfg")
+// ShowProfile prints a profile merged across the config files, the selected
+// one when name is empty.
+func ShowProfile(name string) {
+	layers, err := loadConfigLayers()
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer f.Close()
+	if name == "" {
+		name = profileName
+	}
 
-	reader := bufio.NewReader(f)
-	azSubscriptionID, err := reader.ReadString('\n')
+	name, azEnv, err := mergeConfigLayers(layers).selectProfile(name)
 	if err != nil {
 		log.Fatal(err)
 	}
-	azResourceGroupName, err := reader.ReadString('\n')
+	if name == "" {
+		log.Fatalf("no profiles in %s or %s, run `mario setup` to add one", configFile, userConfigPath())
+	}
+
+	data, err := yaml.Marshal(map[string]AZEnv{name: azEnv})
 	if err != nil {
 		log.Fatal(err)
 	}
-	azDataFactoryName, err := reader.ReadString('\n')
+	fmt.Print(string(data))
+}
+
+// ValidateConfig checks every profile, merged across the config files, and
+// exits non-zero when any of them has a problem.
+func ValidateConfig() {
+	layers, err := loadConfigLayers()
 	if err != nil {
 		log.Fatal(err)
 	}
+	config := mergeConfigLayers(layers)
+	if len(config.Profiles) == 0 {
+		log.Fatalf("no profiles in %s or %s, run `mario setup` to add one", configFile, userConfigPath())
+	}
 
-	azEnv := AZEnv{
-		SubscriptionID:    strings.TrimSpace(azSubscriptionID),
-		ResourceGroupName: strings.TrimSpace(azResourceGroupName),
-		DataFactoryName:   strings.TrimSpace(azDataFactoryName),
+	headerFmt := color.New(color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+	tbl := table.New("Profile", "Result")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	invalid := 0
+	for _, name := range config.profileNames() {
+		problems := validateProfile(config.Profiles[name])
+		if len(problems) == 0 {
+			tbl.AddRow(name, successColor()("ok"))
+			continue
+		}
+
+		invalid++
+		for i, problem := range problems {
+			if i > 0 {
+				name = ""
+			}
+			tbl.AddRow(name, failureColor()(problem))
+		}
 	}
+	tbl.Print()
 
-	return azEnv
+	if config.Current != "" {
+		if _, exists := config.Profiles[config.Current]; !exists {
+			log.Fatalf("current profile %q does not exist", config.Current)
+		}
+	}
+	for _, pattern := range config.Compare.Ignore {
+		if _, err := parseIgnoreRule(pattern); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, sla := range config.SLA {
+		if _, err := parseSLA(sla, time.Local); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if invalid > 0 {
+		log.Fatalf("%d of %d profiles are invalid", invalid, len(config.Profiles))
+	}
+}
+
+func validateProfile(azEnv AZEnv) []string {
+	problems := []string{}
+	if azEnv.SubscriptionID == "" {
+		problems = append(problems, "subscriptionId is required")
+	} else if _, err := uuid.Parse(azEnv.SubscriptionID); err != nil {
+		problems = append(problems, fmt.Sprintf("subscriptionId %q is not a GUID", azEnv.SubscriptionID))
+	}
+	if azEnv.ResourceGroupName == "" {
+		problems = append(problems, "resourceGroup is required")
+	}
+	if azEnv.DataFactoryName == "" {
+		problems = append(problems, "factory is required")
+	}
+	if !slices.Contains(authMethods, azEnv.authMethod()) {
+		problems = append(problems, fmt.Sprintf(
+			"auth %q is not one of %s",
+			azEnv.Auth,
+			strings.Join(authMethods, ", "),
+		))
+	}
+	if azEnv.Defaults.Days < 0 {
+		problems = append(problems, "defaults.days must not be negative")
+	}
+	if azEnv.Defaults.Timezone != "" {
+		if _, err := time.LoadLocation(azEnv.Defaults.Timezone); err != nil {
+			problems = append(problems, fmt.Sprintf("defaults.timezone: %s", err))
+		}
+	}
+	return problems
+}
+
+func (azEnv AZEnv) authMethod() string {
+	if azEnv.Auth == "" {
+		return "default"
+	}
+	return azEnv.Auth
 }
 
 func parseInput(input string) string {
 	fmt.Println(input)
-	reader := bufio.NewReader(os.Stdin)
-	line, err := reader.ReadString('\n')
-	if err != nil {
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
 		log.Fatal(err)
 	}
-	return line
-}
-
-func HelloConfig() {
-	fmt.Println("Hello from config")
+	return strings.TrimSpace(line)
 }
@@ -0,0 +1,464 @@
+package mario
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+)
+
+// kinds of ActivityChange
+const (
+	activityAdded     = "added"
+	activityRemoved   = "removed"
+	activityRenamed   = "renamed"
+	activityReordered = "reordered"
+	activityModified  = "modified"
+)
+
+// ResourceDiff is the difference between two versions of a resource.
+// Activities are matched by name rather than by position, so inserting one
+// activity does not make every later activity differ. Differences holds
+// everything outside the activities, and Ignored the differences left out by
+// ignore rules.
+type ResourceDiff struct {
+	Activities  []ActivityChange     `json:"activities,omitempty" yaml:"activities,omitempty"`
+	Differences []PipelineDifference `json:"differences,omitempty" yaml:"differences,omitempty"`
+	Ignored     []IgnoredDifference  `json:"ignored,omitempty" yaml:"ignored,omitempty"`
+}
+
+// ActivityChange is one activity that was added, removed, renamed, moved or
+// modified. Position1 and Position2 are 1-based indexes in each pipeline.
+// Differences of a modified activity have paths relative to the activity,
+// starting with the property that changed such as policy or typeProperties.
+type ActivityChange struct {
+	Change      string               `json:"change" yaml:"change"`
+	Activity    string               `json:"activity" yaml:"activity"`
+	Type        string               `json:"type" yaml:"type"`
+	RenamedFrom string               `json:"renamedFrom,omitempty" yaml:"renamedFrom,omitempty"`
+	Position1   int                  `json:"position1,omitempty" yaml:"position1,omitempty"`
+	Position2   int                  `json:"position2,omitempty" yaml:"position2,omitempty"`
+	Differences []PipelineDifference `json:"differences,omitempty" yaml:"differences,omitempty"`
+}
+
+// diffRecord is one row of a ResourceDiff in the csv and markdown outputs.
+type diffRecord struct {
+	Change   string `json:"change"`
+	Activity string `json:"activity"`
+	Path     string `json:"path"`
+	Value1   string `json:"value1"`
+	Value2   string `json:"value2"`
+}
+
+func (diff ResourceDiff) equal() bool {
+	return len(diff.Activities) == 0 && len(diff.Differences) == 0
+}
+
+// changes counts activity changes and other differences.
+func (diff ResourceDiff) changes() int {
+	return len(diff.Activities) + len(diff.Differences)
+}
+
+// records flattens the diff into one row per difference.
+func (diff ResourceDiff) records() []diffRecord {
+	records := []diffRecord{}
+	for _, change := range diff.Activities {
+		switch change.Change {
+		case activityModified:
+			for _, difference := range change.Differences {
+				records = append(records, diffRecord{
+					Change:   change.Change,
+					Activity: change.Activity,
+					Path:     difference.Path,
+					Value1:   difference.Value1,
+					Value2:   difference.Value2,
+				})
+			}
+		case activityRenamed:
+			records = append(records, diffRecord{
+				Change:   change.Change,
+				Activity: change.Activity,
+				Path:     "name",
+				Value1:   change.RenamedFrom,
+				Value2:   change.Activity,
+			})
+		default:
+			record := diffRecord{Change: change.Change, Activity: change.Activity}
+			if change.Position1 > 0 {
+				record.Value1 = fmt.Sprint(change.Position1)
+			}
+			if change.Position2 > 0 {
+				record.Value2 = fmt.Sprint(change.Position2)
+			}
+			record.Path = "position"
+			records = append(records, record)
+		}
+	}
+	for _, difference := range diff.Differences {
+		records = append(records, diffRecord{
+			Change: activityModified,
+			Path:   difference.Path,
+			Value1: difference.Value1,
+			Value2: difference.Value2,
+		})
+	}
+	return records
+}
+
+// diffResources compares two resources parsed into maps. Resources without
+// activities, such as datasets, only have Differences.
+func diffResources(resource1 map[string]interface{}, resource2 map[string]interface{}) ResourceDiff {
+	activities1, rest1 := splitActivities(resource1)
+	activities2, rest2 := splitActivities(resource2)
+
+	return ResourceDiff{
+		Activities:  diffActivities(activities1, activities2),
+		Differences: diffValues(rest1, rest2, ""),
+	}
+}
+
+// splitActivities returns properties.activities and a copy of the resource
+// without them.
+func splitActivities(resource map[string]interface{}) ([]map[string]interface{}, map[string]interface{}) {
+	properties, ok := resource["properties"].(map[string]interface{})
+	if !ok {
+		return nil, resource
+	}
+
+	activities := []map[string]interface{}{}
+	if list, ok := properties["activities"].([]interface{}); ok {
+		for _, activity := range list {
+			if activity, ok := activity.(map[string]interface{}); ok {
+				activities = append(activities, activity)
+			}
+		}
+	}
+
+	rest := maps.Clone(resource)
+	restProperties := maps.Clone(properties)
+	delete(restProperties, "activities")
+	rest["properties"] = restProperties
+	return activities, rest
+}
+
+func diffActivities(activities1 []map[string]interface{}, activities2 []map[string]interface{}) []ActivityChange {
+	positions1 := activityPositions(activities1)
+	positions2 := activityPositions(activities2)
+
+	removed := []string{}
+	for _, activity := range activities1 {
+		if _, exists := positions2[activityName(activity)]; !exists {
+			removed = append(removed, activityName(activity))
+		}
+	}
+	added := []string{}
+	for _, activity := range activities2 {
+		if _, exists := positions1[activityName(activity)]; !exists {
+			added = append(added, activityName(activity))
+		}
+	}
+
+	// a removed and an added activity that are identical apart from their
+	// name were renamed
+	renames := map[string]string{}
+	claimed := map[string]bool{}
+	for _, newName := range added {
+		for _, oldName := range removed {
+			if claimed[oldName] {
+				continue
+			}
+			if reflect.DeepEqual(
+				withoutName(activities1[positions1[oldName]]),
+				withoutName(activities2[positions2[newName]]),
+			) {
+				renames[newName] = oldName
+				claimed[oldName] = true
+				break
+			}
+		}
+	}
+
+	// the pipeline1 name of every activity in pipeline2, where there is one
+	previousNames := map[string]string{}
+	for _, activity := range activities2 {
+		name := activityName(activity)
+		if oldName, renamed := renames[name]; renamed {
+			previousNames[name] = oldName
+		} else if _, exists := positions1[name]; exists {
+			previousNames[name] = name
+		}
+	}
+	currentNames := map[string]string{}
+	for name, oldName := range previousNames {
+		currentNames[oldName] = name
+	}
+
+	// activities outside the longest common order of the matched activities
+	// were moved
+	order1 := []string{}
+	for _, activity := range activities1 {
+		if name, matched := currentNames[activityName(activity)]; matched {
+			order1 = append(order1, name)
+		}
+	}
+	order2 := []string{}
+	for _, activity := range activities2 {
+		if _, matched := previousNames[activityName(activity)]; matched {
+			order2 = append(order2, activityName(activity))
+		}
+	}
+	inOrder := longestCommonSubsequence(order1, order2)
+
+	changes := []ActivityChange{}
+	for i, activity := range activities2 {
+		name := activityName(activity)
+		oldName, matched := previousNames[name]
+		if !matched {
+			changes = append(changes, ActivityChange{
+				Change:    activityAdded,
+				Activity:  name,
+				Type:      activityType(activity),
+				Position2: i + 1,
+			})
+			continue
+		}
+
+		position1 := positions1[oldName] + 1
+		if oldName != name {
+			changes = append(changes, ActivityChange{
+				Change:      activityRenamed,
+				Activity:    name,
+				Type:        activityType(activity),
+				RenamedFrom: oldName,
+				Position1:   position1,
+				Position2:   i + 1,
+			})
+		}
+		if !inOrder[name] {
+			changes = append(changes, ActivityChange{
+				Change:    activityReordered,
+				Activity:  name,
+				Type:      activityType(activity),
+				Position1: position1,
+				Position2: i + 1,
+			})
+		}
+
+		// references to renamed activities are compared by their new name
+		activity1 := renameDependencies(activities1[positions1[oldName]], currentNames)
+		differences := diffValues(withoutName(activity1), withoutName(activity), "")
+		if len(differences) > 0 {
+			changes = append(changes, ActivityChange{
+				Change:      activityModified,
+				Activity:    name,
+				Type:        activityType(activity),
+				Position1:   position1,
+				Position2:   i + 1,
+				Differences: differences,
+			})
+		}
+	}
+
+	for _, name := range removed {
+		if _, renamed := currentNames[name]; renamed {
+			continue
+		}
+		changes = append(changes, ActivityChange{
+			Change:    activityRemoved,
+			Activity:  name,
+			Type:      activityType(activities1[positions1[name]]),
+			Position1: positions1[name] + 1,
+		})
+	}
+	return changes
+}
+
+func activityPositions(activities []map[string]interface{}) map[string]int {
+	positions := map[string]int{}
+	for i, activity := range activities {
+		positions[activityName(activity)] = i
+	}
+	return positions
+}
+
+func activityName(activity map[string]interface{}) string {
+	name, _ := activity["name"].(string)
+	return name
+}
+
+func activityType(activity map[string]interface{}) string {
+	activityType, _ := activity["type"].(string)
+	return activityType
+}
+
+func withoutName(activity map[string]interface{}) map[string]interface{} {
+	activity = maps.Clone(activity)
+	delete(activity, "name")
+	return activity
+}
+
+// renameDependencies returns a copy of activity whose dependsOn entries use
+// the new names of renamed activities.
+func renameDependencies(activity map[string]interface{}, currentNames map[string]string) map[string]interface{} {
+	dependsOn, ok := activity["dependsOn"].([]interface{})
+	if !ok {
+		return activity
+	}
+
+	renamed := make([]interface{}, len(dependsOn))
+	for i, dependency := range dependsOn {
+		dependencyMap, ok := dependency.(map[string]interface{})
+		name, _ := dependencyMap["activity"].(string)
+		if newName, exists := currentNames[name]; ok && exists {
+			dependencyMap = maps.Clone(dependencyMap)
+			dependencyMap["activity"] = newName
+			dependency = dependencyMap
+		}
+		renamed[i] = dependency
+	}
+
+	activity = maps.Clone(activity)
+	activity["dependsOn"] = renamed
+	return activity
+}
+
+// longestCommonSubsequence returns the names that keep their relative order
+// in both lists.
+func longestCommonSubsequence(a []string, b []string) map[string]bool {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	inOrder := map[string]bool{}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			inOrder[a[i]] = true
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return inOrder
+}
+
+// diffValues compares two values parsed from JSON and returns every
+// difference, keys in order, with paths like properties.parameters.env and
+// properties.annotations[0]. Values are kept whole, so they may contain
+// anything, including " != ".
+func diffValues(value1 interface{}, value2 interface{}, path string) []PipelineDifference {
+	map1, isMap1 := value1.(map[string]interface{})
+	map2, isMap2 := value2.(map[string]interface{})
+	if isMap1 && isMap2 {
+		keys := []string{}
+		for key := range map1 {
+			keys = append(keys, key)
+		}
+		for key := range map2 {
+			if _, exists := map1[key]; !exists {
+				keys = append(keys, key)
+			}
+		}
+		slices.Sort(keys)
+
+		differences := []PipelineDifference{}
+		for _, key := range keys {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+
+			child1, in1 := map1[key]
+			child2, in2 := map2[key]
+			switch {
+			case !in1:
+				differences = append(differences, valueDifference(keyPath, missingKey, formatValue(child2)))
+			case !in2:
+				differences = append(differences, valueDifference(keyPath, formatValue(child1), missingKey))
+			default:
+				differences = append(differences, diffValues(child1, child2, keyPath)...)
+			}
+		}
+		return differences
+	}
+
+	list1, isList1 := value1.([]interface{})
+	list2, isList2 := value2.([]interface{})
+	if isList1 && isList2 {
+		differences := []PipelineDifference{}
+		for i := 0; i < max(len(list1), len(list2)); i++ {
+			indexPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(list1):
+				differences = append(differences, valueDifference(indexPath, missingElement, formatValue(list2[i])))
+			case i >= len(list2):
+				differences = append(differences, valueDifference(indexPath, formatValue(list1[i]), missingElement))
+			default:
+				differences = append(differences, diffValues(list1[i], list2[i], indexPath)...)
+			}
+		}
+		return differences
+	}
+
+	if reflect.DeepEqual(value1, value2) {
+		return []PipelineDifference{}
+	}
+	formatted1, formatted2 := formatValues(value1, value2)
+	return []PipelineDifference{valueDifference(path, formatted1, formatted2)}
+}
+
+// placeholders for a value that only one side has
+const (
+	missingKey     = "<does not have key>"
+	missingElement = "<no value>"
+)
+
+func valueDifference(path string, value1 string, value2 string) PipelineDifference {
+	return PipelineDifference{Path: path, Value1: value1, Value2: value2}
+}
+
+// formatValue prints a value the way the summary shows it: strings as they
+// are and objects and lists as compact JSON.
+func formatValue(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case map[string]interface{}, []interface{}:
+		valueJSON, err := json.Marshal(value)
+		if err == nil {
+			return string(valueJSON)
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+// formatValues formats two values that differ. Values of different types are
+// both printed as JSON, so the string "1" and the number 1 do not look equal.
+func formatValues(value1 interface{}, value2 interface{}) (string, string) {
+	if reflect.TypeOf(value1) == reflect.TypeOf(value2) {
+		return formatValue(value1), formatValue(value2)
+	}
+	return formatJSON(value1), formatJSON(value2)
+}
+
+func formatJSON(value interface{}) string {
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(valueJSON)
+}
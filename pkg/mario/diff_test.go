@@ -0,0 +1,186 @@
+package mario
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// parseActivities reads a JSON array of activities.
+func parseActivities(t *testing.T, activitiesJSON string) []map[string]interface{} {
+	t.Helper()
+	activities := []map[string]interface{}{}
+	if err := json.Unmarshal([]byte(activitiesJSON), &activities); err != nil {
+		t.Fatal(err)
+	}
+	return activities
+}
+
+func TestDiffActivities(t *testing.T) {
+	const base = `[
+		{"name": "extract", "type": "Copy", "policy": {"timeout": "1h"}},
+		{"name": "transform", "type": "DatabricksNotebook", "dependsOn": [{"activity": "extract"}]},
+		{"name": "load", "type": "Copy", "dependsOn": [{"activity": "transform"}]}
+	]`
+
+	tests := []struct {
+		name        string
+		activities2 string
+		want        []ActivityChange
+	}{
+		{
+			name:        "identical",
+			activities2: base,
+			want:        []ActivityChange{},
+		},
+		{
+			name: "inserted activity does not shift the rest",
+			activities2: `[
+				{"name": "extract", "type": "Copy", "policy": {"timeout": "1h"}},
+				{"name": "validate", "type": "Validation"},
+				{"name": "transform", "type": "DatabricksNotebook", "dependsOn": [{"activity": "extract"}]},
+				{"name": "load", "type": "Copy", "dependsOn": [{"activity": "transform"}]}
+			]`,
+			want: []ActivityChange{
+				{Change: activityAdded, Activity: "validate", Type: "Validation", Position2: 2},
+			},
+		},
+		{
+			name: "removed",
+			activities2: `[
+				{"name": "extract", "type": "Copy", "policy": {"timeout": "1h"}},
+				{"name": "transform", "type": "DatabricksNotebook", "dependsOn": [{"activity": "extract"}]}
+			]`,
+			want: []ActivityChange{
+				{Change: activityRemoved, Activity: "load", Type: "Copy", Position1: 3},
+			},
+		},
+		{
+			name: "modified",
+			activities2: `[
+				{"name": "extract", "type": "Copy", "policy": {"timeout": "2h", "retry": 1}},
+				{"name": "transform", "type": "DatabricksNotebook", "dependsOn": [{"activity": "extract"}]},
+				{"name": "load", "type": "Copy", "dependsOn": [{"activity": "transform"}]}
+			]`,
+			want: []ActivityChange{
+				{
+					Change:    activityModified,
+					Activity:  "extract",
+					Type:      "Copy",
+					Position1: 1,
+					Position2: 1,
+					Differences: []PipelineDifference{
+						{Path: "policy.retry", Value1: missingKey, Value2: "1"},
+						{Path: "policy.timeout", Value1: "1h", Value2: "2h"},
+					},
+				},
+			},
+		},
+		{
+			name: "renamed, with dependencies following the new name",
+			activities2: `[
+				{"name": "extract_raw", "type": "Copy", "policy": {"timeout": "1h"}},
+				{"name": "transform", "type": "DatabricksNotebook", "dependsOn": [{"activity": "extract_raw"}]},
+				{"name": "load", "type": "Copy", "dependsOn": [{"activity": "transform"}]}
+			]`,
+			want: []ActivityChange{
+				{Change: activityRenamed, Activity: "extract_raw", Type: "Copy", RenamedFrom: "extract", Position1: 1, Position2: 1},
+			},
+		},
+		{
+			name: "reordered",
+			activities2: `[
+				{"name": "transform", "type": "DatabricksNotebook", "dependsOn": [{"activity": "extract"}]},
+				{"name": "load", "type": "Copy", "dependsOn": [{"activity": "transform"}]},
+				{"name": "extract", "type": "Copy", "policy": {"timeout": "1h"}}
+			]`,
+			want: []ActivityChange{
+				{Change: activityReordered, Activity: "extract", Type: "Copy", Position1: 1, Position2: 3},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := diffActivities(parseActivities(t, base), parseActivities(t, test.activities2))
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("diffActivities() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDiffValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		value1 string
+		value2 string
+		want   []PipelineDifference
+	}{
+		{
+			name:   "equal",
+			value1: `{"a": {"b": [1, 2]}}`,
+			value2: `{"a": {"b": [1, 2]}}`,
+			want:   []PipelineDifference{},
+		},
+		{
+			name:   "keys in order",
+			value1: `{"b": 1, "a": "x"}`,
+			value2: `{"b": 2, "a": "y"}`,
+			want: []PipelineDifference{
+				{Path: "a", Value1: "x", Value2: "y"},
+				{Path: "b", Value1: "1", Value2: "2"},
+			},
+		},
+		{
+			name:   "missing keys",
+			value1: `{"a": 1}`,
+			value2: `{"b": {"c": true}}`,
+			want: []PipelineDifference{
+				{Path: "a", Value1: "1", Value2: missingKey},
+				{Path: "b", Value1: missingKey, Value2: `{"c":true}`},
+			},
+		},
+		{
+			name:   "list elements",
+			value1: `{"annotations": ["a", "b"]}`,
+			value2: `{"annotations": ["a", "c", "d"]}`,
+			want: []PipelineDifference{
+				{Path: "annotations[1]", Value1: "b", Value2: "c"},
+				{Path: "annotations[2]", Value1: missingElement, Value2: "d"},
+			},
+		},
+		{
+			name:   "values containing the separator",
+			value1: `{"expression": "a != b"}`,
+			value2: `{"expression": "a != c"}`,
+			want: []PipelineDifference{
+				{Path: "expression", Value1: "a != b", Value2: "a != c"},
+			},
+		},
+		{
+			name:   "type change",
+			value1: `{"value": "1"}`,
+			value2: `{"value": 1}`,
+			want: []PipelineDifference{
+				{Path: "value", Value1: `"1"`, Value2: "1"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var value1, value2 interface{}
+			if err := json.Unmarshal([]byte(test.value1), &value1); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(test.value2), &value2); err != nil {
+				t.Fatal(err)
+			}
+			got := diffValues(value1, value2, "")
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("diffValues() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package mario
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/datafactory/armdatafactory/v3"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armsubscriptions"
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+)
+
+// discoveredFactory is a factory found by ListFactories, along with the
+// profile that would reach it.
+type discoveredFactory struct {
+	azEnv            AZEnv
+	subscriptionName string
+	location         string
+	git              string
+	nPipelines       int
+	err              error
+}
+
+// DiscoveredFactory is one factory of ListFactories in the non-table
+// outputs. Error is set when its pipelines could not be counted.
+type DiscoveredFactory struct {
+	SubscriptionID   string `json:"subscriptionId" yaml:"subscriptionId"`
+	SubscriptionName string `json:"subscriptionName" yaml:"subscriptionName"`
+	ResourceGroup    string `json:"resourceGroup" yaml:"resourceGroup"`
+	Factory          string `json:"factory" yaml:"factory"`
+	Location         string `json:"location" yaml:"location"`
+	Git              string `json:"git" yaml:"git"`
+	Pipelines        int    `json:"pipelines" yaml:"pipelines"`
+	Error            string `json:"error" yaml:"error"`
+}
+
+// ListFactories lists every factory the current credential can see across all
+// of its subscriptions. With save the user can pick one to store as a profile.
+func ListFactories(save bool, global bool) {
+	defer timer("ListFactories")()
+	if len(fixtureDirs) > 0 {
+		log.Fatal("factories list reads from Azure and does not work with --fixtures")
+	}
+	if save && !tableOutput() {
+		log.Fatal("--save asks which factory to store and only works with the table output")
+	}
+	ctx := context.Background()
+
+	// only the auth method matters here, the factory settings may be unset
+	azEnv, _, _ := resolveAZEnv(profileName, endpointFallback())
+	cred, clientOptions, err := getCredential(azEnv.authMethod())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	factories, err := discoverFactories(ctx, cred, clientOptions, azEnv.Auth)
+	if err != nil {
+		log.Fatal(err)
+	}
+	countPipelines(ctx, factories)
+
+	if !tableOutput() {
+		if err := writeRecords(discoveredRecords(factories)); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	printDiscoveredFactories(factories)
+
+	if save && len(factories) > 0 {
+		saveDiscoveredFactory(factories, global)
+	}
+}
+
+func discoverFactories(
+	ctx context.Context,
+	cred azcore.TokenCredential,
+	clientOptions *arm.ClientOptions,
+	auth string,
+) ([]*discoveredFactory, error) {
+	subscriptionsClient, err := armsubscriptions.NewClient(cred, clientOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	factories := []*discoveredFactory{}
+	subscriptionPager := subscriptionsClient.NewListPager(nil)
+	for subscriptionPager.More() {
+		page, err := subscriptionPager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, subscription := range page.Value {
+			subscriptionID := stringValue(subscription.SubscriptionID)
+			subscriptionFactories, err := listSubscriptionFactories(
+				ctx,
+				subscriptionID,
+				cred,
+				clientOptions,
+			)
+			if err != nil {
+				// a subscription without the Data Factory provider registered,
+				// or without read access, should not hide the others
+				progressLogger.Printf("skipping subscription %s: %s", subscriptionID, err)
+				continue
+			}
+
+			for _, factory := range subscriptionFactories {
+				resourceID, err := arm.ParseResourceID(stringValue(factory.ID))
+				if err != nil {
+					return nil, err
+				}
+
+				repoConfiguration := armdatafactory.FactoryRepoConfigurationClassification(nil)
+				if factory.Properties != nil {
+					repoConfiguration = factory.Properties.RepoConfiguration
+				}
+
+				factories = append(factories, &discoveredFactory{
+					azEnv: AZEnv{
+						SubscriptionID:    subscriptionID,
+						ResourceGroupName: resourceID.ResourceGroupName,
+						DataFactoryName:   stringValue(factory.Name),
+						Auth:              auth,
+					},
+					subscriptionName: stringValue(subscription.DisplayName),
+					location:         stringValue(factory.Location),
+					git:              describeRepoConfiguration(repoConfiguration),
+				})
+			}
+		}
+	}
+
+	slices.SortFunc(factories, func(a, b *discoveredFactory) int {
+		return strings.Compare(
+			a.subscriptionName+a.azEnv.ResourceGroupName+a.azEnv.DataFactoryName,
+			b.subscriptionName+b.azEnv.ResourceGroupName+b.azEnv.DataFactoryName,
+		)
+	})
+	return factories, nil
+}
+
+func listSubscriptionFactories(
+	ctx context.Context,
+	subscriptionID string,
+	cred azcore.TokenCredential,
+	clientOptions *arm.ClientOptions,
+) ([]*armdatafactory.Factory, error) {
+	clientFactory, err := armdatafactory.NewClientFactory(subscriptionID, cred, clientOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	factories := []*armdatafactory.Factory{}
+	pager := clientFactory.NewFactoriesClient().NewListPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		factories = append(factories, page.Value...)
+	}
+	return factories, nil
+}
+
+// countPipelines reads the pipelines of every factory concurrently.
+func countPipelines(ctx context.Context, discovered []*discoveredFactory) {
+	factories := make([]Factory, len(discovered))
+	for i, factory := range discovered {
+		factories[i] = newFactoryClient(factory.azEnv)
+	}
+
+	type pipelineCount struct {
+		n   int
+		err error
+	}
+	counts := queryFactories(factories, func(factory *Factory) pipelineCount {
+		pipelines, err := factory.source.ListPipelines(ctx)
+		return pipelineCount{n: len(pipelines), err: err}
+	})
+	for i, count := range counts {
+		discovered[i].nPipelines = count.n
+		discovered[i].err = count.err
+	}
+}
+
+func describeRepoConfiguration(
+	repoConfiguration armdatafactory.FactoryRepoConfigurationClassification,
+) string {
+	switch repo := repoConfiguration.(type) {
+	case *armdatafactory.FactoryGitHubConfiguration:
+		return fmt.Sprintf(
+			"github %s/%s@%s",
+			stringValue(repo.AccountName),
+			stringValue(repo.RepositoryName),
+			stringValue(repo.CollaborationBranch),
+		)
+	case *armdatafactory.FactoryVSTSConfiguration:
+		return fmt.Sprintf(
+			"azure devops %s/%s@%s",
+			stringValue(repo.ProjectName),
+			stringValue(repo.RepositoryName),
+			stringValue(repo.CollaborationBranch),
+		)
+	case nil:
+		return "none"
+	}
+	return "unknown"
+}
+
+func discoveredRecords(factories []*discoveredFactory) []DiscoveredFactory {
+	records := make([]DiscoveredFactory, len(factories))
+	for i, factory := range factories {
+		records[i] = DiscoveredFactory{
+			SubscriptionID:   factory.azEnv.SubscriptionID,
+			SubscriptionName: factory.subscriptionName,
+			ResourceGroup:    factory.azEnv.ResourceGroupName,
+			Factory:          factory.azEnv.DataFactoryName,
+			Location:         factory.location,
+			Git:              factory.git,
+			Pipelines:        factory.nPipelines,
+		}
+		if factory.err != nil {
+			records[i].Error = factory.err.Error()
+		}
+	}
+	return records
+}
+
+func printDiscoveredFactories(factories []*discoveredFactory) {
+	headerLength := 80
+
+	header := createHeader(
+		"FACTORIES",
+		headerLength,
+		color.New(color.FgBlue),
+		"=",
+		true,
+	)
+	footer := createHeader("", headerLength, color.New(color.FgWhite), "=", true)
+
+	fmt.Print("\n", header, "\n")
+
+	if len(factories) == 0 {
+		fmt.Println("No data factories found")
+		fmt.Println(footer)
+		return
+	}
+
+	headerFmt := color.New(color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+	tbl := table.New(
+		"#",
+		"Subscription",
+		"Resource Group",
+		"Factory",
+		"Location",
+		"Git",
+		"Pipelines",
+	)
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	for i, factory := range factories {
+		nPipelines := strconv.Itoa(factory.nPipelines)
+		if factory.err != nil {
+			nPipelines = failureColor()("?")
+		}
+
+		subscription := factory.subscriptionName
+		if subscription == "" {
+			subscription = factory.azEnv.SubscriptionID
+		}
+
+		tbl.AddRow(
+			i+1,
+			subscription,
+			factory.azEnv.ResourceGroupName,
+			factory.azEnv.DataFactoryName,
+			factory.location,
+			factory.git,
+			nPipelines,
+		)
+	}
+	tbl.Print()
+
+	fmt.Println(footer)
+}
+
+// saveDiscoveredFactory asks which factory to store and under what profile
+// name, so nobody has to copy subscription ids by hand.
+func saveDiscoveredFactory(factories []*discoveredFactory, global bool) {
+	choice := parseInput(
+		fmt.Sprintf("Save which factory as a profile? (1-%d, blank to skip): ", len(factories)),
+	)
+	if choice == "" {
+		return
+	}
+
+	index, err := strconv.Atoi(choice)
+	if err != nil || index < 1 || index > len(factories) {
+		log.Fatalf("%q is not a number between 1 and %d", choice, len(factories))
+	}
+	factory := factories[index-1]
+
+	name := parseInput(
+		fmt.Sprintf("Enter a name for this profile (%s): ", factory.azEnv.DataFactoryName),
+	)
+	if name == "" {
+		name = factory.azEnv.DataFactoryName
+	}
+
+	path := configPath(global)
+	config, err := loadConfig(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, exists := config.Profiles[name]; exists &&
+		!confirm(fmt.Sprintf("Profile %s already exists, replace it?", name)) {
+		fmt.Println("aborted")
+		return
+	}
+
+	config.Profiles[name] = factory.azEnv
+	if config.Current == "" {
+		config.Current = name
+	}
+	writeConfig(path, config)
+	fmt.Printf("Saved %s as profile %s in %s.\n", factory.azEnv.DataFactoryName, name, path)
+}
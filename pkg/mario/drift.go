@@ -0,0 +1,372 @@
+package mario
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+)
+
+// resourceKinds are the factory resources `compare factories` can cover.
+// Pipelines are always compared, the others on request.
+var resourceKinds = []string{"pipelines", "datasets", "linkedServices", "triggers"}
+
+// statuses of a resource in a FactoryComparison
+const (
+	resourceOnlyInFrom = "onlyInFrom"
+	resourceOnlyInTo   = "onlyInTo"
+	resourceDiffers    = "differs"
+	resourceMatches    = "matches"
+)
+
+// FactoryComparison is the drift between two factories, one entry per
+// resource found in either of them.
+type FactoryComparison struct {
+	From      string               `json:"from" yaml:"from"`
+	To        string               `json:"to" yaml:"to"`
+	Resources []ResourceComparison `json:"resources" yaml:"resources"`
+}
+
+type ResourceComparison struct {
+	Kind         string `json:"kind" yaml:"kind"`
+	Name         string `json:"name" yaml:"name"`
+	Status       string `json:"status" yaml:"status"`
+	ResourceDiff `yaml:",inline"`
+
+	// both versions without ignored values, for printDiffOutput
+	resource1 map[string]interface{}
+	resource2 map[string]interface{}
+}
+
+// resourceDriftRecord is one row of the csv and markdown outputs.
+type resourceDriftRecord struct {
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	Differences int    `json:"differences"`
+}
+
+// factoryResources holds a factory's resources by kind and then by name, as
+// the parsed maps diffResources compares.
+type factoryResources map[string]map[string]map[string]interface{}
+
+// CompareFactories lists the pipelines, and optionally the other resources in
+// include, that exist in only one of two factories, differ, or match. from and
+// to are profiles; with two --fixtures directories they may be left empty.
+// It reports whether the factories have drifted apart.
+func CompareFactories(from string, to string, include []string) (bool, error) {
+	defer timer("CompareFactories")()
+	ctx := context.Background()
+
+	kinds := []string{"pipelines"}
+	for _, kind := range include {
+		if !slices.Contains(resourceKinds, kind) {
+			return false, fmt.Errorf("%q is not one of %s", kind, strings.Join(resourceKinds, ", "))
+		}
+		if !slices.Contains(kinds, kind) {
+			kinds = append(kinds, kind)
+		}
+	}
+
+	factories, err := getComparedFactories(from, to)
+	if err != nil {
+		return false, err
+	}
+
+	type resourcesResult struct {
+		resources factoryResources
+		err       error
+	}
+	results := queryFactories(factories, func(factory *Factory) resourcesResult {
+		resources, err := listFactoryResources(ctx, factory, kinds)
+		return resourcesResult{resources, err}
+	})
+	for i, result := range results {
+		if result.err != nil {
+			return false, fmt.Errorf("%s: %w", factories[i].factoryName, result.err)
+		}
+	}
+
+	rules, err := loadIgnoreRules()
+	if err != nil {
+		return false, err
+	}
+	comparison := compareFactoryResources(
+		results[0].resources,
+		results[1].resources,
+		kinds,
+		rules,
+	)
+	// profiles name the environments better than factory names, which are
+	// often the same in every environment
+	comparison.From = cmp.Or(from, factories[0].factoryName)
+	comparison.To = cmp.Or(to, factories[1].factoryName)
+
+	switch {
+	case reportFormat != "":
+		err = writeFactoryReport(comparison, kinds)
+	case !tableOutput():
+		records := []resourceDriftRecord{}
+		for _, resource := range comparison.Resources {
+			records = append(records, resourceDriftRecord{
+				Kind:        resource.Kind,
+				Name:        resource.Name,
+				Status:      resource.Status,
+				Differences: resource.changes(),
+			})
+		}
+		err = writeOutput(comparison, records)
+	default:
+		printFactoryComparison(comparison, kinds)
+	}
+	return comparison.drifted(), err
+}
+
+// drifted reports whether any resource is missing from a factory or differs.
+func (comparison FactoryComparison) drifted() bool {
+	for _, resource := range comparison.Resources {
+		if resource.Status != resourceMatches {
+			return true
+		}
+	}
+	return false
+}
+
+// statusCounts counts the resources of one kind by status.
+func (comparison FactoryComparison) statusCounts(kind string) map[string]int {
+	counts := map[string]int{}
+	for _, resource := range comparison.Resources {
+		if resource.Kind == kind {
+			counts[resource.Status]++
+		}
+	}
+	return counts
+}
+
+// getComparedFactories returns the from and to factories, which are the
+// first two fixture directories when fixtures are used.
+func getComparedFactories(from string, to string) ([]Factory, error) {
+	if len(fixtureDirs) > 0 {
+		if len(fixtureDirs) != 2 {
+			return nil, errors.New("compare factories needs exactly two --fixtures directories")
+		}
+		factories := make([]Factory, len(fixtureDirs))
+		for i, dir := range fixtureDirs {
+			factory, err := fixtureFactory(dir)
+			if err != nil {
+				return nil, err
+			}
+			factories[i] = factory
+		}
+		return factories, nil
+	}
+
+	if from == "" || to == "" {
+		return nil, errors.New("compare factories needs a profile for both --from and --to")
+	}
+	factories := []Factory{}
+	for _, profile := range []string{from, to} {
+		azEnv, err := resolveFactoryEnv(profile, endpointFallback())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", profile, err)
+		}
+		factory, err := connectFactory(azEnv)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", profile, err)
+		}
+		factories = append(factories, factory)
+	}
+	return factories, nil
+}
+
+func listFactoryResources(
+	ctx context.Context,
+	factory *Factory,
+	kinds []string,
+) (factoryResources, error) {
+	defer timer("listFactoryResources")()
+	resources := factoryResources{}
+	for _, kind := range kinds {
+		var byName map[string]map[string]interface{}
+		var err error
+		switch kind {
+		case "pipelines":
+			byName, err = resourceMaps(factory.source.ListPipelines(ctx))
+		case "datasets":
+			byName, err = resourceMaps(factory.source.ListDatasets(ctx))
+		case "linkedServices":
+			byName, err = resourceMaps(factory.source.ListLinkedServices(ctx))
+		case "triggers":
+			byName, err = resourceMaps(factory.source.ListTriggers(ctx))
+		}
+		if err != nil {
+			return nil, err
+		}
+		resources[kind] = byName
+	}
+	return resources, nil
+}
+
+// resourceMaps converts SDK resources to maps keyed by resource name, without
+// the keys compare ignores.
+func resourceMaps[T any](
+	resources []*T,
+	err error,
+) (map[string]map[string]interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string]map[string]interface{}{}
+	for _, resource := range resources {
+		resourceJson, err := json.Marshal(resource)
+		if err != nil {
+			return nil, err
+		}
+		resourceMap := jsonToMap(string(resourceJson))
+		name, _ := resourceMap["name"].(string)
+		byName[name] = cleanMap(resourceMap, comparedKeysToDrop)
+	}
+	return byName, nil
+}
+
+func compareFactoryResources(
+	from factoryResources,
+	to factoryResources,
+	kinds []string,
+	rules []ignoreRule,
+) FactoryComparison {
+	comparison := FactoryComparison{Resources: []ResourceComparison{}}
+	for _, kind := range kinds {
+		names := []string{}
+		for name := range from[kind] {
+			names = append(names, name)
+		}
+		for name := range to[kind] {
+			if _, exists := from[kind][name]; !exists {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+
+		for _, name := range names {
+			fromResource, inFrom := from[kind][name]
+			toResource, inTo := to[kind][name]
+
+			resource := ResourceComparison{Kind: kind, Name: name}
+			switch {
+			case !inTo:
+				resource.Status = resourceOnlyInFrom
+			case !inFrom:
+				resource.Status = resourceOnlyInTo
+			default:
+				diff, pruned1, pruned2 := diffWithoutIgnored(fromResource, toResource, rules)
+				resource.ResourceDiff = diff
+				resource.Status = resourceMatches
+				if !resource.equal() {
+					resource.Status = resourceDiffers
+					resource.resource1 = pruned1
+					resource.resource2 = pruned2
+				}
+			}
+			comparison.Resources = append(comparison.Resources, resource)
+		}
+	}
+	return comparison
+}
+
+// ignored collects the ignored differences of every resource.
+func (comparison FactoryComparison) ignored() []IgnoredDifference {
+	ignored := []IgnoredDifference{}
+	for _, resource := range comparison.Resources {
+		ignored = append(ignored, resource.Ignored...)
+	}
+	return ignored
+}
+
+func printFactoryComparison(comparison FactoryComparison, kinds []string) {
+	headerLength := 80
+
+	fromColor := color.New(color.FgYellow).SprintFunc()
+	toColor := color.New(color.FgCyan).SprintFunc()
+
+	header := createHeader(
+		"COMPARE FACTORIES",
+		headerLength,
+		color.New(color.FgBlue),
+		"=",
+		true,
+	)
+	footer := createHeader("", headerLength, color.New(color.FgWhite), "=", true)
+
+	fmt.Print("\n", header, "\n")
+	fmt.Println(fromColor(comparison.From), "->", toColor(comparison.To))
+	fmt.Println()
+
+	statusLabels := map[string]string{
+		resourceOnlyInFrom: fromColor("only in " + comparison.From),
+		resourceOnlyInTo:   toColor("only in " + comparison.To),
+		resourceDiffers:    failureColor()("differs"),
+		resourceMatches:    successColor()("matches"),
+	}
+
+	headerFmt := color.New(color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+	summary := table.New("Kind", "Only in "+comparison.From, "Only in "+comparison.To, "Differ", "Match")
+	summary.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	for _, kind := range kinds {
+		counts := comparison.statusCounts(kind)
+		summary.AddRow(
+			kind,
+			counts[resourceOnlyInFrom],
+			counts[resourceOnlyInTo],
+			counts[resourceDiffers],
+			counts[resourceMatches],
+		)
+	}
+	summary.Print()
+	fmt.Println()
+
+	if len(comparison.Resources) == 0 {
+		fmt.Println("No resources found in either factory")
+		fmt.Println(footer)
+		return
+	}
+
+	tbl := table.New("Kind", "Name", "Status", "Differences")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	for _, resource := range comparison.Resources {
+		differences := ""
+		if resource.Status == resourceDiffers {
+			differences = fmt.Sprint(resource.changes())
+		}
+		tbl.AddRow(resource.Kind, resource.Name, statusLabels[resource.Status], differences)
+	}
+	tbl.Print()
+
+	if ignored := comparison.ignored(); len(ignored) > 0 {
+		fmt.Println()
+		fmt.Println(neutralColor()(ignoredSummary(ignored)))
+	}
+	fmt.Println(footer)
+
+	for _, resource := range comparison.Resources {
+		if resource.Status != resourceDiffers {
+			continue
+		}
+		printDiffOutput(
+			resource.ResourceDiff,
+			resource.resource1,
+			resource.resource2,
+			comparison.From+"/"+resource.Name,
+			comparison.To+"/"+resource.Name,
+		)
+	}
+}
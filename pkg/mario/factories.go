@@ -0,0 +1,145 @@
+package mario
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+)
+
+var factorySelection []string
+
+// UseFactories selects profiles, or groups of profiles, for the commands that
+// aggregate several factories.
+func UseFactories(names []string) {
+	factorySelection = names
+}
+
+// getFactoryClients returns a client for every factory an aggregating command
+// should read: each fixture directory, each selected profile, or otherwise
+// just the current factory.
+func getFactoryClients() []Factory {
+	defer timer("getFactoryClients")()
+	factories, err := factoryClients()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return factories
+}
+
+// factoryClients is getFactoryClients for callers that handle the error.
+func factoryClients() ([]Factory, error) {
+	factories := []Factory{}
+	if len(fixtureDirs) > 0 {
+		for _, dir := range fixtureDirs {
+			factory, err := fixtureFactory(dir)
+			if err != nil {
+				return nil, err
+			}
+			factories = append(factories, factory)
+		}
+		return factories, nil
+	}
+
+	if len(factorySelection) == 0 {
+		factory, err := factoryClient()
+		if err != nil {
+			return nil, err
+		}
+		return []Factory{factory}, nil
+	}
+
+	profiles, err := expandProfiles(factorySelection)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, profile := range profiles {
+		azEnv, err := resolveFactoryEnv(profile, endpointFallback())
+		if err != nil {
+			return nil, err
+		}
+		factory, err := connectFactory(azEnv)
+		if err != nil {
+			return nil, err
+		}
+		factory.profile = profile
+		factories = append(factories, factory)
+	}
+	return factories, nil
+}
+
+// allProfiles selects every profile unless a group of that name exists.
+const allProfiles = "all"
+
+// expandProfiles replaces group names with their member profiles, keeping the
+// order they were given in and dropping duplicates.
+func expandProfiles(names []string) ([]string, error) {
+	layers, err := loadConfigLayers()
+	if err != nil {
+		return nil, err
+	}
+	config := mergeConfigLayers(layers)
+
+	profiles := []string{}
+	for _, name := range names {
+		members, isGroup := config.Groups[name]
+		if !isGroup && name == allProfiles {
+			members, isGroup = config.profileNames(), true
+		}
+		if !isGroup {
+			if _, exists := config.Profiles[name]; !exists {
+				return nil, fmt.Errorf("%q is not a profile or a group", name)
+			}
+			members = []string{name}
+		}
+
+		for _, member := range members {
+			if _, exists := config.Profiles[member]; !exists {
+				return nil, fmt.Errorf("group %q refers to missing profile %q", name, member)
+			}
+			if !slices.Contains(profiles, member) {
+				profiles = append(profiles, member)
+			}
+		}
+	}
+	return profiles, nil
+}
+
+// queryFactories calls query for every factory concurrently and returns the
+// results in the same order as factories.
+func queryFactories[T any](factories []Factory, query func(factory *Factory) T) []T {
+	results := make([]T, len(factories))
+
+	var wg sync.WaitGroup
+	for i := range factories {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = query(&factories[i])
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// skipFailedFactories reports the factories whose query returned an error, so
+// that one unreachable factory does not hide the others. It exits when a lone
+// factory, or every factory, failed.
+func skipFailedFactories(factories []Factory, errs []error) {
+	failed := 0
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if len(factories) == 1 {
+			log.Fatal(err)
+		}
+		progressLogger.Printf("skipping factory %s: %s", factories[i].label(), err)
+		failed++
+	}
+	if failed > 0 && failed == len(factories) {
+		log.Fatal("none of the factories could be read")
+	}
+}
@@ -0,0 +1,858 @@
+package mario
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/datafactory/armdatafactory/v3"
+	"github.com/google/uuid"
+)
+
+const factoryRoute = "/subscriptions/{subscription}/resourceGroups/{resourceGroup}/providers/Microsoft.DataFactory/factories/{factory}"
+
+var (
+	armEndpoint string
+
+	// used when talking to a stand-in server for settings no layer sets
+	fakeADFEnv = AZEnv{
+		SubscriptionID:    "00000000-0000-0000-0000-000000000000",
+		ResourceGroupName: "mario-dev",
+		DataFactoryName:   "fake-adf",
+	}
+)
+
+// UseEndpoint points the ARM clients at a different Resource Manager endpoint,
+// such as the one served by ServeFakeADF. A fake credential is only swapped in
+// for loopback endpoints; any other endpoint, like an ARM proxy, keeps the
+// normal credential and certificate checks.
+func UseEndpoint(endpoint string) {
+	armEndpoint = strings.TrimSuffix(endpoint, "/")
+}
+
+// standInEndpoint reports whether the endpoint is a stand-in server on this
+// machine, whose self-signed certificate and fake token mario accepts.
+func standInEndpoint() bool {
+	endpoint, err := url.Parse(armEndpoint)
+	if err != nil {
+		return false
+	}
+	host := endpoint.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// endpointFallback fills in factory settings no layer sets when mario talks
+// to a stand-in server, so it works without any config.
+func endpointFallback() *AZEnv {
+	if armEndpoint == "" || !standInEndpoint() {
+		return nil
+	}
+	return &fakeADFEnv
+}
+
+type fakeCredential struct{}
+
+func (fakeCredential) GetToken(
+	ctx context.Context,
+	options policy.TokenRequestOptions,
+) (azcore.AccessToken, error) {
+	return azcore.AccessToken{
+		Token:     "mario-fake-token",
+		ExpiresOn: time.Now().Add(time.Hour),
+	}, nil
+}
+
+type FakeADFOptions struct {
+	Addr     string
+	Dir      string
+	Speed    float64
+	PageSize int
+}
+
+// ServeFakeADF serves the subset of the Microsoft.DataFactory REST API that
+// mario uses. Pipelines and historical runs come from a fixture directory;
+// runs started through createRun are simulated in memory.
+func ServeFakeADF(options FakeADFOptions) {
+	server := newFakeADF(options)
+
+	certificate, err := selfSignedCertificate()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	listener, err := tls.Listen("tcp", options.Addr, &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	endpoint := "https://" + listener.Addr().String()
+	fmt.Println("serving fake ADF from", options.Dir, "at", endpoint)
+	fmt.Println("point mario at it with:")
+	fmt.Println("  mario --endpoint", endpoint, "summarize runs")
+
+	log.Fatal(http.Serve(listener, server.routes()))
+}
+
+func newFakeADF(options FakeADFOptions) *fakeADF {
+	if options.Speed <= 0 {
+		options.Speed = 1
+	}
+	if options.PageSize <= 0 {
+		options.PageSize = 100
+	}
+
+	return &fakeADF{
+		fixtures: fixtureRunSource{dir: options.Dir},
+		speed:    options.Speed,
+		pageSize: options.PageSize,
+		runs:     map[string]*simulatedRun{},
+	}
+}
+
+type fakeADF struct {
+	fixtures fixtureRunSource
+	speed    float64
+	pageSize int
+
+	mu   sync.Mutex
+	runs map[string]*simulatedRun
+}
+
+func (f *fakeADF) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscriptions", f.listSubscriptions)
+	mux.HandleFunc("GET /subscriptions/{subscription}/providers/Microsoft.DataFactory/factories", f.listFactories)
+	mux.HandleFunc("GET "+factoryRoute+"/pipelines", f.listPipelines)
+	mux.HandleFunc("GET "+factoryRoute+"/pipelines/{pipeline}", f.getPipeline)
+	mux.HandleFunc("POST "+factoryRoute+"/pipelines/{pipeline}/createRun", f.createRun)
+	mux.HandleFunc("GET "+factoryRoute+"/datasets", f.listDatasets)
+	mux.HandleFunc("GET "+factoryRoute+"/linkedservices", f.listLinkedServices)
+	mux.HandleFunc("GET "+factoryRoute+"/triggers", f.listTriggers)
+	mux.HandleFunc("POST "+factoryRoute+"/queryPipelineRuns", f.queryPipelineRuns)
+	mux.HandleFunc("GET "+factoryRoute+"/pipelineruns/{runId}", f.getPipelineRun)
+	mux.HandleFunc("POST "+factoryRoute+"/pipelineruns/{runId}/cancel", f.cancelRun)
+	mux.HandleFunc("POST "+factoryRoute+"/pipelineruns/{runId}/queryActivityruns", f.queryActivityRuns)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeARMError(w, http.StatusNotFound, "NotFound", "no fake route for "+r.Method+" "+r.URL.Path)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Println(r.Method, r.URL.Path)
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// listSubscriptions and listFactories describe a single subscription holding
+// the one fake factory, so factory discovery has something to find.
+func (f *fakeADF) listSubscriptions(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]any{
+		"value": []map[string]any{{
+			"id":             "/subscriptions/" + fakeADFEnv.SubscriptionID,
+			"subscriptionId": fakeADFEnv.SubscriptionID,
+			"displayName":    "mario dev",
+			"state":          "Enabled",
+		}},
+	})
+}
+
+func (f *fakeADF) listFactories(w http.ResponseWriter, r *http.Request) {
+	factories := []*armdatafactory.Factory{}
+	if r.PathValue("subscription") == fakeADFEnv.SubscriptionID {
+		id := fmt.Sprintf(
+			"/subscriptions/%s/resourceGroups/%s/providers/Microsoft.DataFactory/factories/%s",
+			fakeADFEnv.SubscriptionID,
+			fakeADFEnv.ResourceGroupName,
+			fakeADFEnv.DataFactoryName,
+		)
+		factories = append(factories, &armdatafactory.Factory{
+			ID:       &id,
+			Name:     to(fakeADFEnv.DataFactoryName),
+			Location: to("eastus"),
+			Properties: &armdatafactory.FactoryProperties{
+				RepoConfiguration: &armdatafactory.FactoryGitHubConfiguration{
+					Type:                to("FactoryGitHubConfiguration"),
+					AccountName:         to("jeffbrennan"),
+					RepositoryName:      to("mario"),
+					CollaborationBranch: to("main"),
+					RootFolder:          to("/setup/mario_adf"),
+				},
+			},
+		})
+	}
+	writeJSON(w, armdatafactory.FactoryListResponse{Value: factories})
+}
+
+func (f *fakeADF) listPipelines(w http.ResponseWriter, r *http.Request) {
+	pipelines, err := f.fixtures.ListPipelines(r.Context())
+	if err != nil {
+		writeARMError(w, http.StatusInternalServerError, "FixtureError", err.Error())
+		return
+	}
+
+	for _, pipeline := range pipelines {
+		f.decoratePipeline(r, pipeline)
+	}
+	writeJSON(w, armdatafactory.PipelineListResponse{Value: pipelines})
+}
+
+func (f *fakeADF) getPipeline(w http.ResponseWriter, r *http.Request) {
+	pipeline, err := f.fixtures.GetPipeline(r.Context(), r.PathValue("pipeline"))
+	if err != nil {
+		writeARMError(w, http.StatusNotFound, "PipelineNotFound", err.Error())
+		return
+	}
+
+	f.decoratePipeline(r, &pipeline)
+	writeJSON(w, pipeline)
+}
+
+// decoratePipeline fills in the read-only fields ARM adds to every resource.
+func (f *fakeADF) decoratePipeline(
+	r *http.Request,
+	pipeline *armdatafactory.PipelineResource,
+) {
+	id := fmt.Sprintf(
+		"/subscriptions/%s/resourceGroups/%s/providers/Microsoft.DataFactory/factories/%s/pipelines/%s",
+		r.PathValue("subscription"),
+		r.PathValue("resourceGroup"),
+		r.PathValue("factory"),
+		stringValue(pipeline.Name),
+	)
+	pipelineType := "Microsoft.DataFactory/factories/pipelines"
+
+	hash := fnv.New32a()
+	properties, _ := json.Marshal(pipeline.Properties)
+	hash.Write(properties)
+	etag := fmt.Sprintf("%08x-0000-0000-0000-000000000000", hash.Sum32())
+
+	pipeline.ID = &id
+	pipeline.Type = &pipelineType
+	pipeline.Etag = &etag
+}
+
+// listDatasets, listLinkedServices and listTriggers serve the optional
+// fixture subdirectories as-is, for comparing factories.
+func (f *fakeADF) listDatasets(w http.ResponseWriter, r *http.Request) {
+	datasets, err := f.fixtures.ListDatasets(r.Context())
+	if err != nil {
+		writeARMError(w, http.StatusInternalServerError, "FixtureError", err.Error())
+		return
+	}
+	writeJSON(w, armdatafactory.DatasetListResponse{Value: datasets})
+}
+
+func (f *fakeADF) listLinkedServices(w http.ResponseWriter, r *http.Request) {
+	linkedServices, err := f.fixtures.ListLinkedServices(r.Context())
+	if err != nil {
+		writeARMError(w, http.StatusInternalServerError, "FixtureError", err.Error())
+		return
+	}
+	writeJSON(w, armdatafactory.LinkedServiceListResponse{Value: linkedServices})
+}
+
+func (f *fakeADF) listTriggers(w http.ResponseWriter, r *http.Request) {
+	triggers, err := f.fixtures.ListTriggers(r.Context())
+	if err != nil {
+		writeARMError(w, http.StatusInternalServerError, "FixtureError", err.Error())
+		return
+	}
+	writeJSON(w, armdatafactory.TriggerListResponse{Value: triggers})
+}
+
+func (f *fakeADF) createRun(w http.ResponseWriter, r *http.Request) {
+	pipeline, err := f.fixtures.GetPipeline(r.Context(), r.PathValue("pipeline"))
+	if err != nil {
+		writeARMError(w, http.StatusNotFound, "PipelineNotFound", err.Error())
+		return
+	}
+
+	parameters := map[string]any{}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&parameters); err != nil {
+			writeARMError(w, http.StatusBadRequest, "BadRequest", err.Error())
+			return
+		}
+	}
+
+	query := r.URL.Query()
+	run, err := f.newSimulatedRun(r.Context(), pipeline, simulatedRunRequest{
+		parameters:       parameters,
+		referenceRunID:   query.Get("referencePipelineRunId"),
+		isRecovery:       query.Get("isRecovery") == "true",
+		startActivity:    query.Get("startActivityName"),
+		startFromFailure: query.Get("startFromFailure") == "true",
+	})
+	if err != nil {
+		writeARMError(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+
+	f.mu.Lock()
+	f.runs[run.runID] = run
+	f.mu.Unlock()
+
+	writeJSON(w, armdatafactory.CreateRunResponse{RunID: &run.runID})
+}
+
+func (f *fakeADF) queryPipelineRuns(w http.ResponseWriter, r *http.Request) {
+	filter := armdatafactory.RunFilterParameters{}
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+		writeARMError(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+
+	runs, err := f.allPipelineRuns(r.Context())
+	if err != nil {
+		writeARMError(w, http.StatusInternalServerError, "FixtureError", err.Error())
+		return
+	}
+
+	matched := []*armdatafactory.PipelineRun{}
+	for _, run := range runs {
+		if !inRunWindow(run.LastUpdated, filter) {
+			continue
+		}
+		if !matchesRunFilters(filter.Filters, pipelineRunField(run)) {
+			continue
+		}
+		matched = append(matched, run)
+	}
+	orderPipelineRuns(matched, filter.OrderBy)
+
+	offset := 0
+	if filter.ContinuationToken != nil {
+		offset, err = strconv.Atoi(*filter.ContinuationToken)
+		if err != nil || offset < 0 || offset > len(matched) {
+			writeARMError(w, http.StatusBadRequest, "BadRequest", "invalid continuation token")
+			return
+		}
+	}
+
+	end := min(offset+f.pageSize, len(matched))
+	response := armdatafactory.PipelineRunsQueryResponse{Value: matched[offset:end]}
+	if end < len(matched) {
+		token := strconv.Itoa(end)
+		response.ContinuationToken = &token
+	}
+	writeJSON(w, response)
+}
+
+func (f *fakeADF) getPipelineRun(w http.ResponseWriter, r *http.Request) {
+	run, err := f.findPipelineRun(r.Context(), r.PathValue("runId"))
+	if err != nil {
+		writeARMError(w, http.StatusNotFound, "PipelineRunNotFound", err.Error())
+		return
+	}
+	writeJSON(w, run)
+}
+
+func (f *fakeADF) cancelRun(w http.ResponseWriter, r *http.Request) {
+	runID := r.PathValue("runId")
+
+	f.mu.Lock()
+	run, exists := f.runs[runID]
+	if exists && run.cancelledAt.IsZero() {
+		status, _ := run.materialize(time.Now())
+		if isRunActive(stringValue(status.Status)) {
+			run.cancelledAt = time.Now()
+		}
+	}
+	f.mu.Unlock()
+
+	if !exists {
+		writeARMError(w, http.StatusNotFound, "PipelineRunNotFound", "only simulated runs can be cancelled: "+runID)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (f *fakeADF) queryActivityRuns(w http.ResponseWriter, r *http.Request) {
+	filter := armdatafactory.RunFilterParameters{}
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+		writeARMError(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+
+	runID := r.PathValue("runId")
+	f.mu.Lock()
+	run, simulated := f.runs[runID]
+	f.mu.Unlock()
+
+	if !simulated {
+		runs, err := f.fixtures.QueryActivityRuns(r.Context(), runID, filter)
+		if err != nil {
+			writeARMError(w, http.StatusInternalServerError, "FixtureError", err.Error())
+			return
+		}
+		writeJSON(w, runs)
+		return
+	}
+
+	f.mu.Lock()
+	_, activityRuns := run.materialize(time.Now())
+	f.mu.Unlock()
+
+	matched := []*armdatafactory.ActivityRun{}
+	for _, activityRun := range activityRuns {
+		if matchesRunFilters(filter.Filters, activityRunField(activityRun)) {
+			matched = append(matched, activityRun)
+		}
+	}
+	writeJSON(w, armdatafactory.ActivityRunsQueryResponse{Value: matched})
+}
+
+// allPipelineRuns merges fixture runs with simulated runs, ordered by start.
+func (f *fakeADF) allPipelineRuns(
+	ctx context.Context,
+) ([]*armdatafactory.PipelineRun, error) {
+	runs, err := f.fixtures.loadPipelineRuns()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	f.mu.Lock()
+	for _, run := range f.runs {
+		pipelineRun, _ := run.materialize(now)
+		runs = append(runs, pipelineRun)
+	}
+	f.mu.Unlock()
+
+	slices.SortStableFunc(runs, func(a, b *armdatafactory.PipelineRun) int {
+		return compareTimes(a.RunStart, b.RunStart)
+	})
+	return runs, nil
+}
+
+func (f *fakeADF) findPipelineRun(
+	ctx context.Context,
+	runID string,
+) (*armdatafactory.PipelineRun, error) {
+	runs, err := f.allPipelineRuns(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, run := range runs {
+		if stringValue(run.RunID) == runID {
+			return run, nil
+		}
+	}
+	return nil, fmt.Errorf("pipeline run %q not found", runID)
+}
+
+type simulatedRunRequest struct {
+	parameters       map[string]any
+	referenceRunID   string
+	isRecovery       bool
+	startActivity    string
+	startFromFailure bool
+}
+
+type simulatedActivity struct {
+	id           string
+	name         string
+	activityType string
+	duration     time.Duration
+	err          map[string]any
+}
+
+// simulatedRun is a run started against the stand-in server. Its status is
+// derived from the wall clock each time it is read, so runs progress from
+// Queued to InProgress to a terminal state without a background worker.
+type simulatedRun struct {
+	runID        string
+	groupID      string
+	pipelineName string
+	parameters   map[string]*string
+	invokedBy    string
+	queuedAt     time.Time
+	queueDelay   time.Duration
+	cancelledAt  time.Time
+	activities   []simulatedActivity
+}
+
+func (f *fakeADF) newSimulatedRun(
+	ctx context.Context,
+	pipeline armdatafactory.PipelineResource,
+	request simulatedRunRequest,
+) (*simulatedRun, error) {
+	runID := uuid.NewString()
+	run := &simulatedRun{
+		runID:        runID,
+		groupID:      runID,
+		pipelineName: stringValue(pipeline.Name),
+		parameters:   map[string]*string{},
+		invokedBy:    "Manual",
+		queuedAt:     time.Now(),
+		queueDelay:   time.Duration(float64(2*time.Second) / f.speed),
+	}
+	for key, value := range request.parameters {
+		formatted := fmt.Sprint(value)
+		run.parameters[key] = &formatted
+	}
+
+	// activities that already succeeded in the referenced run are skipped
+	skip := map[string]bool{}
+	if request.referenceRunID != "" {
+		reference, err := f.findPipelineRun(ctx, request.referenceRunID)
+		if err != nil {
+			return nil, err
+		}
+		if len(request.parameters) == 0 {
+			run.parameters = reference.Parameters
+		}
+		if request.isRecovery {
+			run.groupID = stringValue(reference.RunGroupID)
+			run.invokedBy = "Rerun"
+		}
+		if request.isRecovery && request.startFromFailure {
+			previous, err := f.activityRunsFor(ctx, request.referenceRunID)
+			if err != nil {
+				return nil, err
+			}
+			for _, activityRun := range previous {
+				if stringValue(activityRun.Status) == "Succeeded" {
+					skip[stringValue(activityRun.ActivityName)] = true
+				}
+			}
+		}
+	}
+
+	history, err := f.activityDurations(pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	started := request.startActivity == ""
+	for _, activity := range orderActivities(pipelineActivities(pipeline)) {
+		if activity.name == request.startActivity {
+			started = true
+		}
+		if !started || skip[activity.name] {
+			continue
+		}
+
+		duration, found := history[activity.name]
+		if !found {
+			duration = 30 * time.Second
+		}
+		simulated := simulatedActivity{
+			id:           uuid.NewString(),
+			name:         activity.name,
+			activityType: activity.activityType,
+			duration:     time.Duration(float64(duration) / f.speed),
+		}
+
+		timeout, hasTimeout := parseActivityTimeout(activity.timeout)
+		switch {
+		case hasTimeout && duration > timeout:
+			simulated.duration = time.Duration(float64(timeout) / f.speed)
+			simulated.err = activityError(
+				"2108",
+				"Activity timed out after "+activity.timeout+".",
+				"SystemError",
+				activity.name,
+			)
+		case strings.Contains(activity.notebookPath, "fail"):
+			simulated.err = activityError(
+				"3204",
+				"Databricks execution failed with error state: InternalError, notebook "+activity.notebookPath+" raised an exception",
+				"UserError",
+				activity.name,
+			)
+		}
+		run.activities = append(run.activities, simulated)
+	}
+
+	return run, nil
+}
+
+func (f *fakeADF) activityRunsFor(
+	ctx context.Context,
+	runID string,
+) ([]*armdatafactory.ActivityRun, error) {
+	f.mu.Lock()
+	run, simulated := f.runs[runID]
+	if simulated {
+		_, activityRuns := run.materialize(time.Now())
+		f.mu.Unlock()
+		return activityRuns, nil
+	}
+	f.mu.Unlock()
+
+	runs, err := f.fixtures.QueryActivityRuns(
+		ctx,
+		runID,
+		armdatafactory.RunFilterParameters{},
+	)
+	return runs.Value, err
+}
+
+// activityDurations returns the median historical duration of each activity
+// in the pipeline, taken from the fixture activity runs.
+func (f *fakeADF) activityDurations(
+	pipeline armdatafactory.PipelineResource,
+) (map[string]time.Duration, error) {
+	activityRuns, err := f.fixtures.loadActivityRuns()
+	if err != nil {
+		return nil, err
+	}
+
+	durationsByActivity := map[string][]int32{}
+	for _, activityRun := range activityRuns {
+		if stringValue(activityRun.PipelineName) != stringValue(pipeline.Name) {
+			continue
+		}
+		if activityRun.DurationInMs == nil {
+			continue
+		}
+		name := stringValue(activityRun.ActivityName)
+		durationsByActivity[name] = append(durationsByActivity[name], *activityRun.DurationInMs)
+	}
+
+	medians := map[string]time.Duration{}
+	for name, durations := range durationsByActivity {
+		slices.Sort(durations)
+		medians[name] = time.Duration(durations[len(durations)/2]) * time.Millisecond
+	}
+	return medians, nil
+}
+
+func (run *simulatedRun) materialize(
+	now time.Time,
+) (*armdatafactory.PipelineRun, []*armdatafactory.ActivityRun) {
+	stopAt := now
+	if !run.cancelledAt.IsZero() && run.cancelledAt.Before(now) {
+		stopAt = run.cancelledAt
+	}
+
+	status := "Queued"
+	lastUpdated := run.queuedAt
+	var runStart *time.Time
+	activityRuns := []*armdatafactory.ActivityRun{}
+
+	t := run.queuedAt.Add(run.queueDelay)
+	if !t.After(stopAt) {
+		start := t
+		runStart = &start
+		lastUpdated = t
+		status = "Succeeded"
+
+		for _, activity := range run.activities {
+			activityStart := t
+			activityEnd := t.Add(activity.duration)
+			activityStatus := "Succeeded"
+			var errorValue any = activityError("", "", "", activity.name)
+
+			switch {
+			case activityEnd.After(stopAt) && stopAt.Before(now):
+				activityStatus = "Cancelled"
+				activityEnd = stopAt
+			case activityEnd.After(stopAt):
+				activityStatus = "InProgress"
+			case activity.err != nil:
+				activityStatus = "Failed"
+				errorValue = activity.err
+			}
+
+			activityRun := &armdatafactory.ActivityRun{
+				ActivityName:     to(activity.name),
+				ActivityRunID:    to(activity.id),
+				ActivityType:     to(activity.activityType),
+				ActivityRunStart: to(activityStart),
+				PipelineName:     to(run.pipelineName),
+				PipelineRunID:    to(run.runID),
+				Status:           to(activityStatus),
+				Input:            map[string]any{},
+				Output:           map[string]any{},
+				Error:            errorValue,
+			}
+			activityRuns = append(activityRuns, activityRun)
+
+			if activityStatus == "InProgress" {
+				status = "InProgress"
+				lastUpdated = activityStart
+				break
+			}
+
+			activityRun.ActivityRunEnd = to(activityEnd)
+			activityRun.DurationInMs = to(int32(activityEnd.Sub(activityStart).Milliseconds()))
+			lastUpdated = activityEnd
+			t = activityEnd
+
+			if activityStatus != "Succeeded" {
+				status = activityStatus
+				break
+			}
+		}
+	} else if stopAt.Before(now) {
+		status = "Cancelled"
+		lastUpdated = stopAt
+	}
+
+	pipelineRun := &armdatafactory.PipelineRun{
+		RunID:        to(run.runID),
+		RunGroupID:   to(run.groupID),
+		IsLatest:     to(true),
+		PipelineName: to(run.pipelineName),
+		Parameters:   run.parameters,
+		InvokedBy: &armdatafactory.PipelineRunInvokedBy{
+			Name:          to(run.invokedBy),
+			InvokedByType: to(run.invokedBy),
+		},
+		RunStart:    runStart,
+		Status:      to(status),
+		LastUpdated: to(lastUpdated),
+		Message:     to(""),
+	}
+
+	if !isRunActive(status) {
+		end := lastUpdated
+		pipelineRun.RunEnd = &end
+		if runStart != nil {
+			pipelineRun.DurationInMs = to(int32(end.Sub(*runStart).Milliseconds()))
+		}
+	}
+	if status == "Failed" {
+		failed := activityRuns[len(activityRuns)-1]
+		failedError, _ := failed.Error.(map[string]any)
+		pipelineRun.Message = to(fmt.Sprintf(
+			"Operation on target %s failed: %v",
+			stringValue(failed.ActivityName),
+			failedError["message"],
+		))
+	}
+
+	return pipelineRun, activityRuns
+}
+
+// parseActivityTimeout parses ADF's d.hh:mm:ss timeout format.
+func parseActivityTimeout(timeout string) (time.Duration, bool) {
+	days := 0
+	if dayPart, rest, found := strings.Cut(timeout, "."); found {
+		parsedDays, err := strconv.Atoi(dayPart)
+		if err != nil {
+			return 0, false
+		}
+		days = parsedDays
+		timeout = rest
+	}
+
+	parts := strings.Split(timeout, ":")
+	if len(parts) != 3 {
+		return 0, false
+	}
+
+	total := time.Duration(days) * 24 * time.Hour
+	for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+		value, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return 0, false
+		}
+		total += time.Duration(value) * unit
+	}
+	return total, true
+}
+
+func activityError(code, message, failureType, target string) map[string]any {
+	return map[string]any{
+		"errorCode":   code,
+		"message":     message,
+		"failureType": failureType,
+		"target":      target,
+		"details":     "",
+	}
+}
+
+func isRunActive(status string) bool {
+	switch status {
+	case "Queued", "InProgress", "Canceling":
+		return true
+	}
+	return false
+}
+
+func compareTimes(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return a.Compare(*b)
+}
+
+func to[T any](v T) *T {
+	return &v
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(err)
+	}
+}
+
+func writeARMError(w http.ResponseWriter, status int, code string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("x-ms-error-code", code)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]string{"code": code, "message": message},
+	})
+}
+
+func selfSignedCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{Organization: []string{"mario fake adf"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1"), net.IPv6loopback},
+		DNSNames:     []string{"localhost"},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}, nil
+}
@@ -0,0 +1,114 @@
+package mario
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestStandInEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     bool
+	}{
+		{endpoint: "https://127.0.0.1:8443", want: true},
+		{endpoint: "https://localhost:8443", want: true},
+		{endpoint: "https://[::1]:8443", want: true},
+		{endpoint: "https://management.azure.com", want: false},
+		{endpoint: "https://arm-proxy.internal:443", want: false},
+		{endpoint: "https://10.0.0.4", want: false},
+		{endpoint: "://not a url", want: false},
+	}
+
+	defer UseEndpoint("")
+	for _, test := range tests {
+		t.Run(test.endpoint, func(t *testing.T) {
+			UseEndpoint(test.endpoint)
+			if got := standInEndpoint(); got != test.want {
+				t.Errorf("standInEndpoint() = %v, want %v", got, test.want)
+			}
+			if got := endpointFallback() != nil; got != test.want {
+				t.Errorf("endpointFallback() set = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// serveFakeADF starts the fake ADF server over fixturesDir and connects a
+// factory to it, counting the pipeline run queries it answers.
+func serveFakeADF(t *testing.T, pageSize int) (*Factory, *atomic.Int32) {
+	t.Helper()
+
+	queries := &atomic.Int32{}
+	routes := newFakeADF(FakeADFOptions{Dir: fixturesDir, PageSize: pageSize}).routes()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/queryPipelineRuns") {
+			queries.Add(1)
+		}
+		routes.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	UseEndpoint(server.URL)
+	t.Cleanup(func() { UseEndpoint("") })
+
+	factory, err := connectFactory(fakeADFEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &factory, queries
+}
+
+func TestFakeADFMatchesFixtures(t *testing.T) {
+	factory, _ := serveFakeADF(t, 7)
+	fixtures, err := fixtureFactory(fixturesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	query := RunQuery{Days: 7}
+	served, err := fetchPipelineRuns(factory, ctx, query, "mario_job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := fetchPipelineRuns(&fixtures, ctx, query, "mario_job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(served.Value) != len(read.Value) {
+		t.Fatalf("fake ADF returned %d runs, fixtures %d", len(served.Value), len(read.Value))
+	}
+
+	for i, run := range read.Value {
+		if stringValue(served.Value[i].RunID) != stringValue(run.RunID) {
+			t.Fatalf("run %d is %s, want %s", i, stringValue(served.Value[i].RunID), stringValue(run.RunID))
+		}
+
+		servedActivities, err := getActivityRuns(factory, ctx, *served.Value[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		readActivities, err := getActivityRuns(&fixtures, ctx, *run)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(servedActivities) == 0 || len(servedActivities) != len(readActivities) {
+			t.Fatalf(
+				"run %s has %d activity runs from fake ADF, %d from fixtures",
+				stringValue(run.RunID),
+				len(servedActivities),
+				len(readActivities),
+			)
+		}
+		for j := range readActivities {
+			served, read := servedActivities[j], readActivities[j]
+			if stringValue(served.ActivityName) != stringValue(read.ActivityName) {
+				t.Errorf("activity %d is %s, want %s", j, stringValue(served.ActivityName), stringValue(read.ActivityName))
+			}
+		}
+	}
+}
@@ -1,12 +1,20 @@
 package mario
 
 import (
+	"crypto/tls"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"os"
 	"strings"
 	"time"
 	"unicode/utf8"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/datafactory/armdatafactory/v3"
 	"github.com/fatih/color"
@@ -55,38 +63,187 @@ func createHeader(
 
 }
 
+var (
+	// timings printed by timer
+	timerOutput io.Writer = os.Stdout
+	// progress and informational messages that are not part of a command's
+	// output
+	progressLogger = log.New(os.Stderr, "", log.LstdFlags)
+)
+
+// quietOutput discards timings and progress messages, for commands that
+// redraw the screen. The returned function restores them.
+func quietOutput() func() {
+	previousTimerOutput := timerOutput
+	previousProgressOutput := progressLogger.Writer()
+
+	timerOutput = io.Discard
+	progressLogger.SetOutput(io.Discard)
+
+	return func() {
+		timerOutput = previousTimerOutput
+		progressLogger.SetOutput(previousProgressOutput)
+	}
+}
+
 func timer(name string) func() {
 	start := time.Now()
 	return func() {
-		fmt.Printf("%s took %v\n", name, time.Since(start))
+		fmt.Fprintf(timerOutput, "%s took %v\n", name, time.Since(start))
 	}
 }
 
+// confirm asks a yes/no question on stdin and defaults to no.
+func confirm(prompt string) bool {
+	fmt.Printf("%s [y/N] ", prompt)
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Println()
+		return false
+	}
+
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
 func getFactoryClient() Factory {
 	defer timer("getFactoryClient")()
-	azEnv := readConfig()
+	factory, err := factoryClient()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return factory
+}
+
+// factoryClient is getFactoryClient for callers that handle the error.
+func factoryClient() (Factory, error) {
+	if len(fixtureDirs) > 0 {
+		return fixtureFactory(fixtureDirs[0])
+	}
+
+	azEnv, err := resolveFactoryEnv(profileName, endpointFallback())
+	if err != nil {
+		return Factory{}, err
+	}
+	return connectFactory(azEnv)
+}
+
+func newFactoryClient(azEnv AZEnv) Factory {
+	factory, err := connectFactory(azEnv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return factory
+}
+
+// connectFactory is newFactoryClient for callers that handle the error.
+func connectFactory(azEnv AZEnv) (Factory, error) {
 	subscriptionID := azEnv.SubscriptionID
 	resourceGroupName := azEnv.ResourceGroupName
 	dataFactoryName := azEnv.DataFactoryName
 
-	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	cred, clientOptions, err := getCredential(azEnv.authMethod())
 	if err != nil {
-		log.Fatal(err)
+		return Factory{}, err
 	}
 
-	datafactoryClientFactory, _ = armdatafactory.NewClientFactory(
+	datafactoryClientFactory, err = armdatafactory.NewClientFactory(
 		subscriptionID,
 		cred,
-		nil,
+		clientOptions,
 	)
+	if err != nil {
+		return Factory{}, err
+	}
 
 	return Factory{
 		subscriptionID:   subscriptionID,
 		resouceGroupName: resourceGroupName,
 		factoryName:      dataFactoryName,
 		factoryClient:    datafactoryClientFactory,
+		source: armRunSource{
+			client:            datafactoryClientFactory,
+			resourceGroupName: resourceGroupName,
+			factoryName:       dataFactoryName,
+		},
+	}, nil
+}
+
+// getCredential returns the Azure credential for a profile's auth method and,
+// with --endpoint, client options for that Resource Manager endpoint. A local
+// ADF stand-in gets a fake credential and its self-signed certificate is
+// trusted.
+func getCredential(auth string) (azcore.TokenCredential, *arm.ClientOptions, error) {
+	if armEndpoint != "" && standInEndpoint() {
+		clientOptions := endpointOptions(armEndpoint)
+		clientOptions.Cloud.Services[cloud.ResourceManager] = cloud.ServiceConfiguration{
+			Endpoint: armEndpoint,
+			Audience: armEndpoint,
+		}
+		// the stand-in server uses a self-signed certificate
+		clientOptions.Transport = &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+		}
+		return fakeCredential{}, clientOptions, nil
+	}
+
+	var cred azcore.TokenCredential
+	var err error
+	switch auth {
+	case "cli":
+		cred, err = azidentity.NewAzureCLICredential(nil)
+	case "environment":
+		cred, err = azidentity.NewEnvironmentCredential(nil)
+	case "managed-identity":
+		cred, err = azidentity.NewManagedIdentityCredential(nil)
+	default:
+		cred, err = azidentity.NewDefaultAzureCredential(nil)
+	}
+	if armEndpoint == "" {
+		return cred, nil, err
 	}
 
+	// a proxy in front of Resource Manager still expects Azure tokens
+	return cred, endpointOptions(armEndpoint), err
+}
+
+// endpointOptions points the ARM clients at endpoint, requesting tokens for
+// the public Resource Manager audience.
+func endpointOptions(endpoint string) *arm.ClientOptions {
+	return &arm.ClientOptions{
+		ClientOptions: policy.ClientOptions{
+			Cloud: cloud.Configuration{
+				Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+					cloud.ResourceManager: {
+						Endpoint: endpoint,
+						Audience: cloud.AzurePublic.Services[cloud.ResourceManager].Audience,
+					},
+				},
+			},
+		},
+	}
+}
+
+func getFixtureFactory(dir string) Factory {
+	factory, err := fixtureFactory(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return factory
+}
+
+func fixtureFactory(dir string) (Factory, error) {
+	manifest, err := readFixtureManifest(dir)
+	if err != nil {
+		return Factory{}, err
+	}
+
+	return Factory{
+		factoryName: manifest.Name,
+		source:      fixtureRunSource{dir: dir},
+	}, nil
 }
 
 func successColor() func(a ...interface{}) string {
@@ -100,3 +257,8 @@ func failureColor() func(a ...interface{}) string {
 func neutralColor() func(a ...interface{}) string {
 	return color.New(color.FgWhite).SprintFunc()
 }
+
+// subtotalFormat styles the subtotal rows of tables that aggregate factories.
+func subtotalFormat(a ...interface{}) string {
+	return color.New(color.Bold).Sprint(a...)
+}
@@ -0,0 +1,378 @@
+package mario
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ignorePatterns []string
+
+// CompareConfig holds the compare settings of a config file.
+type CompareConfig struct {
+	// Ignore lists paths whose differences are expected, such as
+	// properties.activities[*].policy.timeout
+	Ignore []string `yaml:"ignore,omitempty"`
+}
+
+// ignoreRule is a parsed ignore pattern. Keys are separated by dots and
+// indexes written in brackets. * matches any key, [*] any index, ** any
+// number of keys and indexes, and an activity can be named instead of
+// indexed, as in properties.activities[copy_data].policy. A rule also
+// ignores everything below the path it matches.
+type ignoreRule struct {
+	pattern  string
+	segments []pathSegment
+}
+
+// pathSegment is one key or index of a difference's path. alias is the name
+// of the activity at an index, so rules can refer to it by name.
+type pathSegment struct {
+	key   string
+	index string
+	alias string
+}
+
+// IgnoredDifference is a difference left out of a comparison by a rule.
+type IgnoredDifference struct {
+	Path string `json:"path" yaml:"path"`
+	Rule string `json:"rule" yaml:"rule"`
+}
+
+// UseIgnorePatterns adds ignore patterns from flags to those in the config.
+func UseIgnorePatterns(patterns []string) {
+	ignorePatterns = patterns
+}
+
+// loadIgnoreRules returns the rules from the config files and from flags.
+func loadIgnoreRules() ([]ignoreRule, error) {
+	layers, err := loadConfigLayers()
+	if err != nil {
+		return nil, err
+	}
+	patterns := append(mergeConfigLayers(layers).Compare.Ignore, ignorePatterns...)
+
+	rules := []ignoreRule{}
+	for _, pattern := range patterns {
+		rule, err := parseIgnoreRule(pattern)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseIgnoreRule(pattern string) (ignoreRule, error) {
+	segments, err := parsePath(pattern)
+	if err != nil {
+		return ignoreRule{}, fmt.Errorf("ignore rule %q: %w", pattern, err)
+	}
+	if len(segments) == 0 {
+		return ignoreRule{}, fmt.Errorf("ignore rule %q is empty", pattern)
+	}
+	return ignoreRule{pattern: pattern, segments: segments}, nil
+}
+
+// parsePath splits a path like properties.activities[0].policy into keys
+// and indexes.
+func parsePath(path string) ([]pathSegment, error) {
+	segments := []pathSegment{}
+	key := strings.Builder{}
+	endKey := func() {
+		if key.Len() > 0 {
+			segments = append(segments, pathSegment{key: key.String()})
+			key.Reset()
+		}
+	}
+
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '.':
+			endKey()
+		case '[':
+			endKey()
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [ at %d", i)
+			}
+			segments = append(segments, pathSegment{index: path[i+1 : i+end]})
+			i += end
+		default:
+			key.WriteByte(path[i])
+		}
+	}
+	endKey()
+	return segments, nil
+}
+
+func (rule ignoreRule) matches(path []pathSegment) bool {
+	return matchSegments(rule.segments, path)
+}
+
+// matchSegments reports whether pattern matches path or one of its parents.
+func matchSegments(pattern []pathSegment, path []pathSegment) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	if pattern[0].key == "**" {
+		for skip := 0; skip <= len(path); skip++ {
+			if matchSegments(pattern[1:], path[skip:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 || !matchSegment(pattern[0], path[0]) {
+		return false
+	}
+	return matchSegments(pattern[1:], path[1:])
+}
+
+func matchSegment(pattern pathSegment, segment pathSegment) bool {
+	if pattern.key != "" {
+		return segment.key != "" && (pattern.key == "*" || pattern.key == segment.key)
+	}
+	if segment.key != "" {
+		return false
+	}
+	return pattern.index == "*" ||
+		pattern.index == segment.index ||
+		(segment.alias != "" && pattern.index == segment.alias)
+}
+
+// formatPath joins segments back into a path, naming activities rather than
+// indexing them.
+func formatPath(segments []pathSegment) string {
+	path := ""
+	for _, segment := range segments {
+		switch {
+		case segment.key != "" && path == "":
+			path = segment.key
+		case segment.key != "":
+			path += "." + segment.key
+		case segment.alias != "":
+			path += "[" + segment.alias + "]"
+		default:
+			path += "[" + segment.index + "]"
+		}
+	}
+	return path
+}
+
+// ignoredBy returns the first rule matching path, if any.
+func ignoredBy(rules []ignoreRule, path []pathSegment) (ignoreRule, bool) {
+	for _, rule := range rules {
+		if rule.matches(path) {
+			return rule, true
+		}
+	}
+	return ignoreRule{}, false
+}
+
+// diffWithoutIgnored compares two resources after removing the values
+// matched by rules, so ignored differences never take the place of real
+// ones, and lists the ignored values that differ. The pruned resources are
+// returned for the diff formats that show whole documents.
+func diffWithoutIgnored(
+	resource1 map[string]interface{},
+	resource2 map[string]interface{},
+	rules []ignoreRule,
+) (ResourceDiff, map[string]interface{}, map[string]interface{}) {
+	pruned1 := pruneIgnored(resource1, rules)
+	pruned2 := pruneIgnored(resource2, rules)
+
+	diff := diffResources(pruned1, pruned2)
+	if len(rules) > 0 {
+		diff.Ignored = ignoredDifferences(resource1, resource2, nil, rules)
+	}
+	return diff, pruned1, pruned2
+}
+
+// ignoredDifferences walks both values and lists each path matched by a rule
+// whose values differ. Matched values are not walked any further, so a rule
+// counts once for each value it hides however much of it changed. Keys are
+// visited in order, so the list is the same on every run.
+func ignoredDifferences(
+	value1 interface{},
+	value2 interface{},
+	path []pathSegment,
+	rules []ignoreRule,
+) []IgnoredDifference {
+	ignored := []IgnoredDifference{}
+	visit := func(child1 interface{}, child2 interface{}, childPath []pathSegment) {
+		rule, matched := ignoredBy(rules, childPath)
+		switch {
+		case !matched:
+			ignored = append(ignored, ignoredDifferences(child1, child2, childPath, rules)...)
+		case !reflect.DeepEqual(child1, child2):
+			ignored = append(ignored, IgnoredDifference{Path: formatPath(childPath), Rule: rule.pattern})
+		}
+	}
+
+	map1, isMap1 := value1.(map[string]interface{})
+	map2, isMap2 := value2.(map[string]interface{})
+	if isMap1 || isMap2 {
+		keys := []string{}
+		for key := range map1 {
+			keys = append(keys, key)
+		}
+		for key := range map2 {
+			if _, exists := map1[key]; !exists {
+				keys = append(keys, key)
+			}
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			visit(map1[key], map2[key], append(slices.Clip(path), pathSegment{key: key}))
+		}
+		return ignored
+	}
+
+	list1, isList1 := value1.([]interface{})
+	list2, isList2 := value2.([]interface{})
+	if isList1 || isList2 {
+		for _, pair := range pairElements(list1, list2) {
+			visit(pair.value1, pair.value2, append(slices.Clip(path), pair.segment))
+		}
+	}
+	return ignored
+}
+
+// elementPair is an element of two versions of a list, either of which may
+// be missing, and the path segment it is matched by.
+type elementPair struct {
+	segment pathSegment
+	value1  interface{}
+	value2  interface{}
+}
+
+// pairElements pairs list elements that have a name, like activities, by
+// that name, and other elements by index. Paired elements take their index
+// in list2, or in list1 when they were removed.
+func pairElements(list1 []interface{}, list2 []interface{}) []elementPair {
+	names1 := elementNames(list1)
+	names2 := elementNames(list2)
+	pairs := []elementPair{}
+
+	if names1 == nil || names2 == nil {
+		for i := 0; i < max(len(list1), len(list2)); i++ {
+			pair := elementPair{segment: pathSegment{index: strconv.Itoa(i)}}
+			if i < len(list1) {
+				pair.value1 = list1[i]
+			}
+			if i < len(list2) {
+				pair.value2 = list2[i]
+			}
+			pairs = append(pairs, pair)
+		}
+		return pairs
+	}
+
+	for i, name := range names2 {
+		pair := elementPair{
+			segment: pathSegment{index: strconv.Itoa(i), alias: name},
+			value2:  list2[i],
+		}
+		if j := slices.Index(names1, name); j >= 0 {
+			pair.value1 = list1[j]
+		}
+		pairs = append(pairs, pair)
+	}
+	for i, name := range names1 {
+		if !slices.Contains(names2, name) {
+			pairs = append(pairs, elementPair{
+				segment: pathSegment{index: strconv.Itoa(i), alias: name},
+				value1:  list1[i],
+			})
+		}
+	}
+	return pairs
+}
+
+// elementNames returns the name of every element, or nil unless every
+// element is an object with a unique name.
+func elementNames(list []interface{}) []string {
+	names := make([]string, len(list))
+	for i, element := range list {
+		elementMap, _ := element.(map[string]interface{})
+		name, _ := elementMap["name"].(string)
+		if name == "" || slices.Contains(names[:i], name) {
+			return nil
+		}
+		names[i] = name
+	}
+	return names
+}
+
+// pruneIgnored returns a copy of a resource without the values matched by
+// rules, for the diff formats that show whole documents.
+func pruneIgnored(resource map[string]interface{}, rules []ignoreRule) map[string]interface{} {
+	if len(rules) == 0 {
+		return resource
+	}
+	pruned, _ := pruneValue(resource, nil, rules).(map[string]interface{})
+	return pruned
+}
+
+func pruneValue(value interface{}, path []pathSegment, rules []ignoreRule) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		pruned := map[string]interface{}{}
+		for key, child := range value {
+			childPath := append(slices.Clip(path), pathSegment{key: key})
+			if _, ignored := ignoredBy(rules, childPath); !ignored {
+				pruned[key] = pruneValue(child, childPath, rules)
+			}
+		}
+		return pruned
+	case []interface{}:
+		pruned := []interface{}{}
+		for i, child := range value {
+			// elements with a name, like activities, can be matched by it
+			name := ""
+			if childMap, ok := child.(map[string]interface{}); ok {
+				name, _ = childMap["name"].(string)
+			}
+			childPath := append(
+				slices.Clip(path),
+				pathSegment{index: strconv.Itoa(i), alias: name},
+			)
+			if _, ignored := ignoredBy(rules, childPath); !ignored {
+				pruned = append(pruned, pruneValue(child, childPath, rules))
+			}
+		}
+		return pruned
+	}
+	return value
+}
+
+// ignoredSummary is the line printed under a comparison that had ignored
+// differences, e.g. "ignored 2 differences: properties.folder.name (1), ...".
+func ignoredSummary(ignored []IgnoredDifference) string {
+	if len(ignored) == 0 {
+		return ""
+	}
+
+	rules := []string{}
+	counts := map[string]int{}
+	for _, difference := range ignored {
+		if counts[difference.Rule] == 0 {
+			rules = append(rules, difference.Rule)
+		}
+		counts[difference.Rule]++
+	}
+
+	parts := make([]string, len(rules))
+	for i, rule := range rules {
+		parts[i] = fmt.Sprintf("%s (%d)", rule, counts[rule])
+	}
+
+	noun := "differences"
+	if len(ignored) == 1 {
+		noun = "difference"
+	}
+	return fmt.Sprintf("ignored %d %s: %s", len(ignored), noun, strings.Join(parts, ", "))
+}
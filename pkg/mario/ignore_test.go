@@ -0,0 +1,161 @@
+package mario
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMatchSegments(t *testing.T) {
+	// the activity at index 2 of the path is named copy_data
+	path := []pathSegment{
+		{key: "properties"},
+		{key: "activities"},
+		{index: "2", alias: "copy_data"},
+		{key: "policy"},
+		{key: "timeout"},
+	}
+
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{pattern: "properties.activities[2].policy.timeout", want: true},
+		{pattern: "properties.activities[2].policy", want: true},
+		{pattern: "properties", want: true},
+		{pattern: "properties.activities[*].policy.timeout", want: true},
+		{pattern: "properties.activities[copy_data].policy", want: true},
+		{pattern: "properties.*[2].policy", want: true},
+		{pattern: "**.timeout", want: true},
+		{pattern: "**.policy", want: true},
+		{pattern: "properties.**.timeout", want: true},
+		{pattern: "**", want: true},
+		{pattern: "properties.activities[1].policy", want: false},
+		{pattern: "properties.activities[load_data]", want: false},
+		{pattern: "properties.activities.policy", want: false},
+		{pattern: "properties.activities[*].policy.retry", want: false},
+		{pattern: "**.retry", want: false},
+		{pattern: "properties.activities[2].policy.timeout.value", want: false},
+		{pattern: "activities", want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern, func(t *testing.T) {
+			rule, err := parseIgnoreRule(test.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := matchSegments(rule.segments, path); got != test.want {
+				t.Errorf("matchSegments(%s) = %v, want %v", test.pattern, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseIgnoreRuleErrors(t *testing.T) {
+	for _, pattern := range []string{"", "properties.activities[0", "."} {
+		if _, err := parseIgnoreRule(pattern); err == nil {
+			t.Errorf("parseIgnoreRule(%q) succeeded, want an error", pattern)
+		}
+	}
+}
+
+func TestIgnoredDifferences(t *testing.T) {
+	const pipeline1 = `{
+		"name": "mario_job",
+		"properties": {
+			"folder": {"name": "dev"},
+			"annotations": ["dev"],
+			"parameters": {"env": {"type": "string", "defaultValue": "dev"}},
+			"activities": [
+				{"name": "extract", "policy": {"timeout": "1h", "retry": 0}},
+				{"name": "load", "policy": {"timeout": "1h", "retry": 0}}
+			]
+		}
+	}`
+	const pipeline2 = `{
+		"name": "mario_job",
+		"properties": {
+			"folder": {"name": "prod"},
+			"annotations": ["prod", "critical"],
+			"parameters": {"env": {"type": "string", "defaultValue": "prod"}},
+			"activities": [
+				{"name": "load", "policy": {"timeout": "2h", "retry": 3}},
+				{"name": "extract", "policy": {"timeout": "1h", "retry": 3}}
+			]
+		}
+	}`
+
+	tests := []struct {
+		name     string
+		patterns []string
+		want     []IgnoredDifference
+	}{
+		{
+			name:     "no rules",
+			patterns: []string{},
+			want:     []IgnoredDifference{},
+		},
+		{
+			name:     "a rule counts once however much below it changed",
+			patterns: []string{"properties.annotations"},
+			want: []IgnoredDifference{
+				{Path: "properties.annotations", Rule: "properties.annotations"},
+			},
+		},
+		{
+			name:     "keys in order",
+			patterns: []string{"properties.parameters.*.defaultValue", "properties.folder"},
+			want: []IgnoredDifference{
+				{Path: "properties.folder", Rule: "properties.folder"},
+				{Path: "properties.parameters.env.defaultValue", Rule: "properties.parameters.*.defaultValue"},
+			},
+		},
+		{
+			name:     "activities matched by name",
+			patterns: []string{"**.retry"},
+			want: []IgnoredDifference{
+				{Path: "properties.activities[load].policy.retry", Rule: "**.retry"},
+				{Path: "properties.activities[extract].policy.retry", Rule: "**.retry"},
+			},
+		},
+		{
+			name:     "named activity",
+			patterns: []string{"properties.activities[load].policy"},
+			want: []IgnoredDifference{
+				{Path: "properties.activities[load].policy", Rule: "properties.activities[load].policy"},
+			},
+		},
+		{
+			name:     "equal values are not counted",
+			patterns: []string{"name", "properties.activities[extract].policy.timeout"},
+			want:     []IgnoredDifference{},
+		},
+	}
+
+	var value1, value2 interface{}
+	if err := json.Unmarshal([]byte(pipeline1), &value1); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(pipeline2), &value2); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules := []ignoreRule{}
+			for _, pattern := range test.patterns {
+				rule, err := parseIgnoreRule(pattern)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rules = append(rules, rule)
+			}
+
+			got := ignoredDifferences(value1, value2, nil, rules)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ignoredDifferences() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
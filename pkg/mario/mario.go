@@ -1,7 +1,9 @@
 package mario
 
 import (
+	"cmp"
 	"os"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/datafactory/armdatafactory/v3"
 )
@@ -10,13 +12,35 @@ var (
 	datafactoryClientFactory *armdatafactory.ClientFactory
 )
 
+// PipelineRunSummary counts a pipeline's runs by status. The runtime
+// statistics cover finished runs only, since queued and in progress runs have
+// no final duration.
 type PipelineRunSummary struct {
-	PipelineName    string
-	Success         int
-	Failed          int
-	InProgress      int
-	RuntimeTotalMin float32
-	RuntimeAvgMin   float32
+	FactoryName      string    `json:"factoryName" yaml:"factoryName"`
+	Profile          string    `json:"profile,omitempty" yaml:"profile,omitempty"`
+	PipelineName     string    `json:"pipelineName" yaml:"pipelineName"`
+	Success          int       `json:"success" yaml:"success"`
+	Failed           int       `json:"failed" yaml:"failed"`
+	Cancelled        int       `json:"cancelled" yaml:"cancelled"`
+	InProgress       int       `json:"inProgress" yaml:"inProgress"`
+	RuntimeTotalMin  float32   `json:"runtimeTotalMin" yaml:"runtimeTotalMin"`
+	RuntimeAvgMin    float32   `json:"runtimeAvgMin" yaml:"runtimeAvgMin"`
+	RuntimeP50Min    float32   `json:"runtimeP50Min" yaml:"runtimeP50Min"`
+	RuntimeP90Min    float32   `json:"runtimeP90Min" yaml:"runtimeP90Min"`
+	RuntimeP95Min    float32   `json:"runtimeP95Min" yaml:"runtimeP95Min"`
+	RuntimeMaxMin    float32   `json:"runtimeMaxMin" yaml:"runtimeMaxMin"`
+	RuntimeStdDevMin float32   `json:"runtimeStdDevMin" yaml:"runtimeStdDevMin"`
+	SuccessRate      float32   `json:"successRate" yaml:"successRate"`
+	LastStatus       string    `json:"lastStatus" yaml:"lastStatus"`
+	LastRunStart     time.Time `json:"lastRunStart" yaml:"lastRunStart"`
+
+	// durations of the finished runs in minutes, and of the succeeded runs
+	// alone for the runtime statistics
+	durations          []float64
+	succeededDurations []float64
+	// index of the factory among those read, which tells apart factories
+	// with the same name
+	factory int
 }
 
 type Factory struct {
@@ -24,6 +48,19 @@ type Factory struct {
 	resouceGroupName string
 	factoryName      string
 	factoryClient    *armdatafactory.ClientFactory
+	source           RunSource
+	// the profile an aggregating command read the factory with, if any
+	profile string
+}
+
+// label names the factory in the output of commands that read several. Dev
+// and prod factories often share a name, so the profile or fixture directory
+// is preferred.
+func (factory *Factory) label() string {
+	if source, isFixture := factory.source.(fixtureRunSource); isFixture {
+		return source.dir
+	}
+	return cmp.Or(factory.profile, factory.factoryName)
 }
 
 func Exit() {
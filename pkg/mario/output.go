@@ -0,0 +1,156 @@
+package mario
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// outputFormats are the values --output accepts. table is the colored
+// terminal output, the others are meant for other programs to read.
+var outputFormats = []string{"table", "json", "csv", "markdown", "yaml"}
+
+var outputFormat = "table"
+
+// UseOutput selects how commands print their results. Machine readable
+// formats move timings to stderr so stdout only holds the data.
+func UseOutput(format string) error {
+	if format == "" {
+		format = "table"
+	}
+	if !slices.Contains(outputFormats, format) {
+		return fmt.Errorf(
+			"output %q is not one of %s",
+			format,
+			strings.Join(outputFormats, ", "),
+		)
+	}
+	outputFormat = format
+	if format != "table" {
+		timerOutput = os.Stderr
+	}
+	return nil
+}
+
+func tableOutput() bool {
+	return outputFormat == "table"
+}
+
+// writeOutput prints document as json or yaml, or records as csv or markdown
+// rows, in the selected output format. records must be a slice of structs;
+// their json tags name the columns so every format uses the same field names.
+func writeOutput(document any, records any) error {
+	return writeFormat(os.Stdout, outputFormat, document, records)
+}
+
+// writeRecords is writeOutput for commands whose result is just a list.
+func writeRecords(records any) error {
+	return writeOutput(records, records)
+}
+
+func writeFormat(w io.Writer, format string, document any, records any) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(document)
+	case "yaml":
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(document); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case "csv":
+		columns, rows := recordRows(records)
+		csvWriter := csv.NewWriter(w)
+		csvWriter.Write(columns)
+		csvWriter.WriteAll(rows)
+		return csvWriter.Error()
+	case "markdown":
+		columns, rows := recordRows(records)
+		return writeMarkdownTable(w, columns, rows)
+	}
+	return fmt.Errorf("cannot write %s output", format)
+}
+
+// recordRows flattens a slice of structs into a header and rows of strings.
+func recordRows(records any) ([]string, [][]string) {
+	value := reflect.ValueOf(records)
+	recordType := value.Type().Elem()
+
+	columns := []string{}
+	fields := []int{}
+	for i := 0; i < recordType.NumField(); i++ {
+		field := recordType.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		columns = append(columns, name)
+		fields = append(fields, i)
+	}
+
+	rows := make([][]string, value.Len())
+	for i := range rows {
+		record := value.Index(i)
+		row := make([]string, len(fields))
+		for j, field := range fields {
+			row[j] = formatField(record.Field(field).Interface())
+		}
+		rows[i] = row
+	}
+	return columns, rows
+}
+
+func formatField(value any) string {
+	switch value := value.(type) {
+	case time.Time:
+		if value.IsZero() {
+			return ""
+		}
+		return value.Format(time.RFC3339)
+	case float32:
+		return fmt.Sprintf("%.2f", value)
+	case float64:
+		return fmt.Sprintf("%.2f", value)
+	case []string:
+		return strings.Join(value, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+func writeMarkdownTable(w io.Writer, columns []string, rows [][]string) error {
+	escape := strings.NewReplacer("|", "\\|", "\n", " ")
+
+	separators := make([]string, len(columns))
+	for i := range separators {
+		separators[i] = "---"
+	}
+
+	lines := []string{
+		"| " + strings.Join(columns, " | ") + " |",
+		"| " + strings.Join(separators, " | ") + " |",
+	}
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = escape.Replace(cell)
+		}
+		lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+	}
+
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
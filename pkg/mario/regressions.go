@@ -0,0 +1,166 @@
+package mario
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+)
+
+// DurationShift is a lasting change in a pipeline's duration. The runs
+// before it are those since the previous shift, the runs after it those until
+// the next one.
+type DurationShift struct {
+	FactoryName    string    `json:"factoryName" yaml:"factoryName"`
+	PipelineName   string    `json:"pipelineName" yaml:"pipelineName"`
+	ShiftTime      time.Time `json:"shiftTime" yaml:"shiftTime"`
+	LastRunBefore  string    `json:"lastRunBefore" yaml:"lastRunBefore"`
+	FirstRunAfter  string    `json:"firstRunAfter" yaml:"firstRunAfter"`
+	MedianBeforeMs int64     `json:"medianBeforeMs" yaml:"medianBeforeMs"`
+	MedianAfterMs  int64     `json:"medianAfterMs" yaml:"medianAfterMs"`
+	PctChange      float64   `json:"pctChange" yaml:"pctChange"`
+	RunsBefore     int       `json:"runsBefore" yaml:"runsBefore"`
+	RunsAfter      int       `json:"runsAfter" yaml:"runsAfter"`
+}
+
+// AnalyzeRegressions finds the points where the duration of each pipeline
+// matching name moved to a new level and stayed there. Only successful runs
+// are used, since failures often stop early.
+func AnalyzeRegressions(query RunQuery, name string, minRuns int, minChangePct float64) {
+	defer timer("AnalyzeRegressions")()
+	factories := getFactoryClients()
+	ctx := context.Background()
+
+	factoryShifts := queryFactories(factories, func(factory *Factory) []DurationShift {
+		pipelineRuns, _ := getPipelineRuns(factory, ctx, query, "")
+		runStats, _ := collectPipelineRunStats(pipelineRuns)
+
+		runsByPipeline := map[string][]RunStats{}
+		for _, run := range runStats {
+			if run.Status == "Succeeded" && strings.Contains(run.PipelineName, name) {
+				run.FactoryName = factory.factoryName
+				runsByPipeline[run.PipelineName] = append(runsByPipeline[run.PipelineName], run)
+			}
+		}
+
+		shifts := []DurationShift{}
+		for _, runs := range runsByPipeline {
+			shifts = append(shifts, findDurationShifts(runs, minRuns, minChangePct)...)
+		}
+		return shifts
+	})
+
+	shifts := []DurationShift{}
+	for _, factoryShift := range factoryShifts {
+		shifts = append(shifts, factoryShift...)
+	}
+	// the most recent shifts are the ones still worth acting on
+	slices.SortFunc(shifts, func(a, b DurationShift) int {
+		return b.ShiftTime.Compare(a.ShiftTime)
+	})
+
+	if !tableOutput() {
+		if err := writeRecords(shifts); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	printRegressions(shifts, len(factories) > 1)
+}
+
+// findDurationShifts runs change-point detection over one pipeline's runs.
+func findDurationShifts(runs []RunStats, minRuns int, minChangePct float64) []DurationShift {
+	slices.SortFunc(runs, func(a, b RunStats) int {
+		return a.StartTime.Compare(b.StartTime)
+	})
+	durations := make([]float64, len(runs))
+	for i, run := range runs {
+		durations[i] = float64(run.DurationMs)
+	}
+
+	points := changePoints(durations, minRuns, minChangePct)
+	bounds := append(append([]int{0}, points...), len(runs))
+
+	shifts := []DurationShift{}
+	for i, point := range points {
+		before := durations[bounds[i]:point]
+		after := durations[point:bounds[i+2]]
+		medianBefore := median(before)
+		medianAfter := median(after)
+
+		shifts = append(shifts, DurationShift{
+			FactoryName:    runs[point].FactoryName,
+			PipelineName:   runs[point].PipelineName,
+			ShiftTime:      runs[point].StartTime,
+			LastRunBefore:  runs[point-1].RunID,
+			FirstRunAfter:  runs[point].RunID,
+			MedianBeforeMs: int64(medianBefore),
+			MedianAfterMs:  int64(medianAfter),
+			PctChange:      math.Round((medianAfter-medianBefore)/medianBefore*10000) / 100,
+			RunsBefore:     len(before),
+			RunsAfter:      len(after),
+		})
+	}
+	return shifts
+}
+
+func printRegressions(shifts []DurationShift, byFactory bool) {
+	defer timer("printRegressions")()
+	headerLength := 80
+
+	header := createHeader(
+		"REGRESSIONS",
+		headerLength,
+		color.New(color.FgBlue),
+		"=",
+		true,
+	)
+	footer := createHeader("", headerLength, color.New(color.FgWhite), "=", true)
+
+	fmt.Print("\n", header, "\n")
+
+	if len(shifts) == 0 {
+		fmt.Println(successColor()("No lasting duration shifts found"))
+		fmt.Println(footer)
+		return
+	}
+
+	headerFmt := color.New(color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+	columns := []interface{}{"Pipeline", "Shift", "Before", "After", "Change", "Runs Before", "Runs After"}
+	if byFactory {
+		columns = append([]interface{}{"Factory"}, columns...)
+	}
+	tbl := table.New(columns...)
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	for _, shift := range shifts {
+		change := successColor()(fmt.Sprintf("↓%.2f%%", math.Abs(shift.PctChange)))
+		if shift.PctChange > 0 {
+			change = failureColor()(fmt.Sprintf("↑%.2f%%", shift.PctChange))
+		}
+
+		row := []interface{}{
+			shift.PipelineName,
+			shift.ShiftTime.Format("2006-01-02 15:04"),
+			formatMs(float64(shift.MedianBeforeMs)),
+			formatMs(float64(shift.MedianAfterMs)),
+			change,
+			shift.RunsBefore,
+			shift.RunsAfter,
+		}
+		if byFactory {
+			row = append([]interface{}{shift.FactoryName}, row...)
+		}
+		tbl.AddRow(row...)
+	}
+	tbl.Print()
+	fmt.Println(footer)
+}
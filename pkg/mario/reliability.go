@@ -0,0 +1,240 @@
+package mario
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/datafactory/armdatafactory/v3"
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+)
+
+// PipelineReliability scores a pipeline's succeeded and failed runs; runs
+// with other statuses are skipped. A failure streak is a run of consecutive
+// failures. MTBF is the mean time between the starts of failure streaks and
+// MTTR the mean time from the start of a streak to the end of the run that
+// recovered from it. Flakiness is the share of consecutive run pairs whose
+// status flipped between Succeeded and Failed.
+type PipelineReliability struct {
+	FactoryName          string    `json:"factoryName" yaml:"factoryName"`
+	PipelineName         string    `json:"pipelineName" yaml:"pipelineName"`
+	Runs                 int       `json:"runs" yaml:"runs"`
+	Failures             int       `json:"failures" yaml:"failures"`
+	FailureRate          float64   `json:"failureRate" yaml:"failureRate"`
+	Flips                int       `json:"flips" yaml:"flips"`
+	Flakiness            float64   `json:"flakiness" yaml:"flakiness"`
+	LongestFailureStreak int       `json:"longestFailureStreak" yaml:"longestFailureStreak"`
+	CurrentFailureStreak int       `json:"currentFailureStreak" yaml:"currentFailureStreak"`
+	MTBFHours            float64   `json:"mtbfHours" yaml:"mtbfHours"`
+	MTTRHours            float64   `json:"mttrHours" yaml:"mttrHours"`
+	LastFailure          time.Time `json:"lastFailure" yaml:"lastFailure"`
+}
+
+// AnalyzeReliability ranks the pipelines matching name from the flakiest,
+// then by failure rate and longest failure streak.
+func AnalyzeReliability(query RunQuery, name string) {
+	defer timer("AnalyzeReliability")()
+	factories := getFactoryClients()
+	ctx := context.Background()
+
+	factoryScores := queryFactories(
+		factories,
+		func(factory *Factory) map[string]PipelineReliability {
+			pipelineRuns, _ := getPipelineRuns(factory, ctx, query, "")
+			return scorePipelineReliability(pipelineRuns)
+		},
+	)
+
+	scores := []PipelineReliability{}
+	for i, factoryScore := range factoryScores {
+		for _, score := range factoryScore {
+			if !strings.Contains(score.PipelineName, name) {
+				continue
+			}
+			score.FactoryName = factories[i].factoryName
+			scores = append(scores, score)
+		}
+	}
+
+	slices.SortFunc(scores, func(a, b PipelineReliability) int {
+		return cmp.Or(
+			cmp.Compare(b.Flakiness, a.Flakiness),
+			cmp.Compare(b.FailureRate, a.FailureRate),
+			cmp.Compare(b.LongestFailureStreak, a.LongestFailureStreak),
+			strings.Compare(a.FactoryName, b.FactoryName),
+			strings.Compare(a.PipelineName, b.PipelineName),
+		)
+	})
+
+	if !tableOutput() {
+		if err := writeRecords(scores); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	printReliability(scores, len(factories) > 1)
+}
+
+// scorePipelineReliability walks the same runs as summarizePipelineRuns, in
+// start order per pipeline.
+func scorePipelineReliability(
+	runs armdatafactory.PipelineRunsQueryResponse,
+) map[string]PipelineReliability {
+	defer timer("scorePipelineReliability")()
+
+	runsByPipeline := map[string][]*armdatafactory.PipelineRun{}
+	for _, run := range runs.Value {
+		if run.RunStart == nil || run.Status == nil {
+			continue
+		}
+		if *run.Status != "Succeeded" && *run.Status != "Failed" {
+			continue
+		}
+		runsByPipeline[*run.PipelineName] = append(runsByPipeline[*run.PipelineName], run)
+	}
+
+	scores := map[string]PipelineReliability{}
+	for pipelineName, pipelineRuns := range runsByPipeline {
+		slices.SortFunc(pipelineRuns, func(a, b *armdatafactory.PipelineRun) int {
+			return a.RunStart.Compare(*b.RunStart)
+		})
+		score := scoreReliability(pipelineRuns)
+		score.PipelineName = pipelineName
+		scores[pipelineName] = score
+	}
+	return scores
+}
+
+func scoreReliability(runs []*armdatafactory.PipelineRun) PipelineReliability {
+	score := PipelineReliability{Runs: len(runs)}
+
+	streakStarts := []time.Time{}
+	repairTimes := []float64{}
+	previousStatus := ""
+	for _, run := range runs {
+		failed := *run.Status == "Failed"
+		if previousStatus != "" && *run.Status != previousStatus {
+			score.Flips++
+		}
+
+		switch {
+		case failed:
+			score.Failures++
+			score.CurrentFailureStreak++
+			score.LongestFailureStreak = max(score.LongestFailureStreak, score.CurrentFailureStreak)
+			score.LastFailure = *run.RunStart
+			if score.CurrentFailureStreak == 1 {
+				streakStarts = append(streakStarts, *run.RunStart)
+			}
+		case score.CurrentFailureStreak > 0:
+			// the first success after a streak recovers from it
+			recovered := *run.RunStart
+			if run.RunEnd != nil {
+				recovered = *run.RunEnd
+			}
+			streakStart := streakStarts[len(streakStarts)-1]
+			repairTimes = append(repairTimes, recovered.Sub(streakStart).Hours())
+			score.CurrentFailureStreak = 0
+		}
+		previousStatus = *run.Status
+	}
+
+	score.FailureRate = roundTo(float64(score.Failures)/float64(score.Runs)*100, 2)
+	if score.Runs > 1 {
+		score.Flakiness = roundTo(float64(score.Flips)/float64(score.Runs-1), 3)
+	}
+
+	gaps := []float64{}
+	for i := 1; i < len(streakStarts); i++ {
+		gaps = append(gaps, streakStarts[i].Sub(streakStarts[i-1]).Hours())
+	}
+	score.MTBFHours = roundTo(mean(gaps), 2)
+	score.MTTRHours = roundTo(mean(repairTimes), 2)
+	return score
+}
+
+func roundTo(value float64, digits int) float64 {
+	scale := math.Pow(10, float64(digits))
+	return math.Round(value*scale) / scale
+}
+
+func printReliability(scores []PipelineReliability, byFactory bool) {
+	defer timer("printReliability")()
+	headerLength := 80
+
+	header := createHeader(
+		"RELIABILITY",
+		headerLength,
+		color.New(color.FgBlue),
+		"=",
+		true,
+	)
+	footer := createHeader("", headerLength, color.New(color.FgWhite), "=", true)
+
+	fmt.Print("\n", header, "\n")
+
+	if len(scores) == 0 {
+		fmt.Println("No succeeded or failed runs found")
+		fmt.Println(footer)
+		return
+	}
+
+	headerFmt := color.New(color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+	columns := []interface{}{
+		"Pipeline",
+		"Flaky",
+		"Fail %",
+		"Runs",
+		"\u2718",
+		"Longest Streak",
+		"Current Streak",
+		"MTBF",
+		"MTTR",
+	}
+	if byFactory {
+		columns = append([]interface{}{"Factory"}, columns...)
+	}
+	tbl := table.New(columns...)
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	for _, score := range scores {
+		currentStreak := fmt.Sprint(score.CurrentFailureStreak)
+		if score.CurrentFailureStreak > 0 {
+			currentStreak = failureColor()(currentStreak)
+		}
+
+		row := []interface{}{
+			score.PipelineName,
+			fmt.Sprintf("%.0f%%", score.Flakiness*100),
+			fmt.Sprintf("%.1f", score.FailureRate),
+			score.Runs,
+			score.Failures,
+			score.LongestFailureStreak,
+			currentStreak,
+			formatHours(score.MTBFHours),
+			formatHours(score.MTTRHours),
+		}
+		if byFactory {
+			row = append([]interface{}{score.FactoryName}, row...)
+		}
+		tbl.AddRow(row...)
+	}
+	tbl.Print()
+	fmt.Println(footer)
+}
+
+// formatHours prints a duration in hours, or - when it could not be measured.
+func formatHours(hours float64) string {
+	if hours == 0 {
+		return "-"
+	}
+	return (time.Duration(hours * float64(time.Hour))).Truncate(time.Minute).String()
+}
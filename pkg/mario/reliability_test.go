@@ -0,0 +1,124 @@
+package mario
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/datafactory/armdatafactory/v3"
+)
+
+// hourlyRuns returns a ten minute run of pipeline every hour from midnight
+// UTC on 2024-03-01, one per letter of statuses: S succeeded, F failed and C
+// cancelled.
+func hourlyRuns(pipeline string, statuses string) []*armdatafactory.PipelineRun {
+	names := map[rune]string{'S': "Succeeded", 'F': "Failed", 'C': "Cancelled"}
+	midnight := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	runs := []*armdatafactory.PipelineRun{}
+	for i, status := range statuses {
+		start := midnight.Add(time.Duration(i) * time.Hour)
+		runs = append(runs, &armdatafactory.PipelineRun{
+			PipelineName: to(pipeline),
+			Status:       to(names[status]),
+			RunStart:     to(start),
+			RunEnd:       to(start.Add(10 * time.Minute)),
+		})
+	}
+	return runs
+}
+
+func TestScoreReliability(t *testing.T) {
+	midnight := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		statuses string
+		want     PipelineReliability
+	}{
+		{
+			statuses: "SSSS",
+			want:     PipelineReliability{Runs: 4},
+		},
+		{
+			statuses: "FFFF",
+			want: PipelineReliability{
+				Runs:                 4,
+				Failures:             4,
+				FailureRate:          100,
+				LongestFailureStreak: 4,
+				CurrentFailureStreak: 4,
+				LastFailure:          midnight.Add(3 * time.Hour),
+			},
+		},
+		{
+			statuses: "SFSF",
+			want: PipelineReliability{
+				Runs:                 4,
+				Failures:             2,
+				FailureRate:          50,
+				Flips:                3,
+				Flakiness:            1,
+				LongestFailureStreak: 1,
+				CurrentFailureStreak: 1,
+				MTBFHours:            2,
+				MTTRHours:            1.17,
+				LastFailure:          midnight.Add(3 * time.Hour),
+			},
+		},
+		{
+			statuses: "SFFFSS",
+			want: PipelineReliability{
+				Runs:                 6,
+				Failures:             3,
+				FailureRate:          50,
+				Flips:                2,
+				Flakiness:            0.4,
+				LongestFailureStreak: 3,
+				MTTRHours:            3.17,
+				LastFailure:          midnight.Add(3 * time.Hour),
+			},
+		},
+		{
+			statuses: "FSSFFS",
+			want: PipelineReliability{
+				Runs:                 6,
+				Failures:             3,
+				FailureRate:          50,
+				Flips:                3,
+				Flakiness:            0.6,
+				LongestFailureStreak: 2,
+				MTBFHours:            3,
+				MTTRHours:            1.67,
+				LastFailure:          midnight.Add(4 * time.Hour),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.statuses, func(t *testing.T) {
+			got := scoreReliability(hourlyRuns("mario_job", test.statuses))
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("scoreReliability() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestScorePipelineReliability(t *testing.T) {
+	runs := append(hourlyRuns("mario_job", "SCFCS"), hourlyRuns("copy_iris_data", "SS")...)
+	// runs are scored in start order whatever order they were returned in
+	runs[0], runs[4] = runs[4], runs[0]
+
+	scores := scorePipelineReliability(armdatafactory.PipelineRunsQueryResponse{Value: runs})
+	if len(scores) != 2 {
+		t.Fatalf("scored %d pipelines, want 2", len(scores))
+	}
+
+	job := scores["mario_job"]
+	if job.PipelineName != "mario_job" || job.Runs != 3 || job.Failures != 1 || job.Flips != 2 {
+		t.Errorf("mario_job = %+v, want 3 runs, 1 failure and 2 flips without the cancelled runs", job)
+	}
+	if copyData := scores["copy_iris_data"]; copyData.Runs != 2 || copyData.Failures != 0 {
+		t.Errorf("copy_iris_data = %+v, want 2 runs and no failures", copyData)
+	}
+}
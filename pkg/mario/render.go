@@ -0,0 +1,392 @@
+package mario
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+// diffFormats are the ways compare can print differences in table output.
+// summary lists each change by path, unified and side-by-side show the
+// canonical JSON of both resources.
+var diffFormats = []string{"summary", "unified", "side-by-side"}
+
+var (
+	diffFormat       = "summary"
+	diffContextLines = 3
+)
+
+// UseDiffFormat selects how compare prints differences and how many
+// unchanged lines surround each change in the unified and side-by-side
+// formats.
+func UseDiffFormat(format string, contextLines int) error {
+	if !slices.Contains(diffFormats, format) {
+		return fmt.Errorf(
+			"format %q is not one of %s",
+			format,
+			strings.Join(diffFormats, ", "),
+		)
+	}
+	diffFormat = format
+	diffContextLines = max(contextLines, 0)
+	return nil
+}
+
+// diffOp is one line of a line diff: ' ' for a line in both documents, '-'
+// for a line only in the first and '+' for a line only in the second. line1
+// and line2 are 0-based line numbers, -1 on the side the line is missing
+// from.
+type diffOp struct {
+	kind  byte
+	line1 int
+	line2 int
+	text  string
+}
+
+// diffHunk is a run of changes with the unchanged lines around them.
+// before1 and before2 count the lines of each document ahead of the hunk.
+type diffHunk struct {
+	ops     []diffOp
+	before1 int
+	before2 int
+}
+
+// canonicalLines formats a resource as indented JSON. Maps are written with
+// sorted keys, so the same resource always produces the same lines.
+func canonicalLines(resource map[string]interface{}) []string {
+	data, err := json.MarshalIndent(resource, "", "  ")
+	if err != nil {
+		return []string{fmt.Sprint(resource)}
+	}
+	return strings.Split(string(data), "\n")
+}
+
+// diffLines finds the shortest edit script between a and b with Myers'
+// algorithm.
+func diffLines(a []string, b []string) []diffOp {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+
+	// v as it was before each step, to walk the edits back
+	trace := [][]int{}
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, slices.Clone(v))
+		done := false
+		for k := -d; k <= d; k += 2 {
+			x := 0
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				done = true
+				break
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	ops := []diffOp{}
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		previousK := k - 1
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			previousK = k + 1
+		}
+		previousX := v[offset+previousK]
+		previousY := previousX - previousK
+
+		for x > previousX && y > previousY {
+			x--
+			y--
+			ops = append(ops, diffOp{kind: ' ', line1: x, line2: y, text: a[x]})
+		}
+		if x == previousX {
+			y--
+			ops = append(ops, diffOp{kind: '+', line1: -1, line2: y, text: b[y]})
+		} else {
+			x--
+			ops = append(ops, diffOp{kind: '-', line1: x, line2: -1, text: a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, diffOp{kind: ' ', line1: x, line2: y, text: a[x]})
+	}
+
+	slices.Reverse(ops)
+	return ops
+}
+
+// diffHunks groups the changes in ops with up to context unchanged lines on
+// either side, merging changes whose context overlaps.
+func diffHunks(ops []diffOp, context int) []diffHunk {
+	hunks := []diffHunk{}
+	addHunk := func(start int, end int) {
+		hunk := diffHunk{ops: ops[start : end+1]}
+		for _, op := range ops[:start] {
+			if op.line1 >= 0 {
+				hunk.before1++
+			}
+			if op.line2 >= 0 {
+				hunk.before2++
+			}
+		}
+		hunks = append(hunks, hunk)
+	}
+
+	start, end := -1, -1
+	for i, op := range ops {
+		if op.kind == ' ' {
+			continue
+		}
+		if start >= 0 && i-context <= end {
+			end = min(i+context, len(ops)-1)
+			continue
+		}
+		if start >= 0 {
+			addHunk(start, end)
+		}
+		start = max(i-context, 0)
+		end = min(i+context, len(ops)-1)
+	}
+	if start >= 0 {
+		addHunk(start, end)
+	}
+	return hunks
+}
+
+// header is the @@ -start,count +start,count @@ line of a hunk.
+func (hunk diffHunk) header() string {
+	count1, count2 := 0, 0
+	for _, op := range hunk.ops {
+		if op.line1 >= 0 {
+			count1++
+		}
+		if op.line2 >= 0 {
+			count2++
+		}
+	}
+	return fmt.Sprintf(
+		"@@ -%s +%s @@",
+		hunkRange(hunk.before1, count1),
+		hunkRange(hunk.before2, count2),
+	)
+}
+
+// hunkRange is the start,count of a hunk given the lines before it. An empty
+// range starts at the line before it, like diff -u.
+func hunkRange(before int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+func printUnifiedDiff(
+	resource1 map[string]interface{},
+	resource2 map[string]interface{},
+	name1 string,
+	name2 string,
+) {
+	removedColor := color.New(color.FgRed).SprintFunc()
+	addedColor := color.New(color.FgGreen).SprintFunc()
+	hunkColor := color.New(color.FgCyan).SprintFunc()
+
+	lines := unifiedDiffLines(resource1, resource2, name1, name2)
+	for i, line := range lines {
+		switch {
+		case i < 2 && strings.HasPrefix(line, "---"):
+			fmt.Println(removedColor(line))
+		case i < 2 && strings.HasPrefix(line, "+++"):
+			fmt.Println(addedColor(line))
+		case strings.HasPrefix(line, "@@"):
+			fmt.Println(hunkColor(line))
+		case strings.HasPrefix(line, "-"):
+			fmt.Println(removedColor(line))
+		case strings.HasPrefix(line, "+"):
+			fmt.Println(addedColor(line))
+		default:
+			fmt.Println(line)
+		}
+	}
+}
+
+// unifiedDiffLines is the uncolored unified diff of two resources, starting
+// with the --- and +++ lines naming them.
+func unifiedDiffLines(
+	resource1 map[string]interface{},
+	resource2 map[string]interface{},
+	name1 string,
+	name2 string,
+) []string {
+	lines := []string{"--- " + name1, "+++ " + name2}
+	ops := diffLines(canonicalLines(resource1), canonicalLines(resource2))
+	for _, hunk := range diffHunks(ops, diffContextLines) {
+		lines = append(lines, hunk.header())
+		for _, op := range hunk.ops {
+			lines = append(lines, string(op.kind)+op.text)
+		}
+	}
+	return lines
+}
+
+// sideBySideRow is one line of each document, either of which may be
+// missing.
+type sideBySideRow struct {
+	left     string
+	right    string
+	hasLeft  bool
+	hasRight bool
+	changed  bool
+}
+
+func printSideBySideDiff(
+	resource1 map[string]interface{},
+	resource2 map[string]interface{},
+	name1 string,
+	name2 string,
+) {
+	removedColor := color.New(color.FgRed).SprintFunc()
+	addedColor := color.New(color.FgGreen).SprintFunc()
+	changedColor := color.New(color.FgYellow).SprintFunc()
+	hunkColor := color.New(color.FgCyan).SprintFunc()
+
+	// two columns and a 3 character gutter fill the terminal
+	columnWidth := max((terminalWidth()-3)/2, 10)
+
+	fmt.Println(
+		color.New(color.Underline).Sprint(padRight(truncate(name1, columnWidth), columnWidth)),
+		" ",
+		color.New(color.Underline).Sprint(truncate(name2, columnWidth)),
+	)
+
+	ops := diffLines(canonicalLines(resource1), canonicalLines(resource2))
+	for _, hunk := range diffHunks(ops, diffContextLines) {
+		fmt.Println(hunkColor(hunk.header()))
+
+		for _, row := range sideBySideRows(hunk.ops) {
+			gutter := " "
+			leftColor, rightColor := fmt.Sprint, fmt.Sprint
+			switch {
+			case row.changed:
+				gutter = "|"
+				leftColor, rightColor = changedColor, changedColor
+			case row.hasLeft && !row.hasRight:
+				gutter = "<"
+				leftColor = removedColor
+			case row.hasRight && !row.hasLeft:
+				gutter = ">"
+				rightColor = addedColor
+			}
+
+			// long lines wrap onto as many rows as they need
+			left := wrapLine(row.left, columnWidth)
+			right := wrapLine(row.right, columnWidth)
+			for i := 0; i < max(len(left), len(right)); i++ {
+				leftPart, rightPart := "", ""
+				if i < len(left) {
+					leftPart = left[i]
+				}
+				if i < len(right) {
+					rightPart = right[i]
+				}
+				fmt.Println(
+					leftColor(padRight(leftPart, columnWidth)),
+					gutter,
+					rightColor(rightPart),
+				)
+				gutter = " "
+			}
+		}
+	}
+}
+
+// sideBySideRows pairs the removed and added lines of each change, so a
+// modified line is shown next to its replacement.
+func sideBySideRows(ops []diffOp) []sideBySideRow {
+	rows := []sideBySideRow{}
+	removed, added := []string{}, []string{}
+	flush := func() {
+		for i := 0; i < max(len(removed), len(added)); i++ {
+			row := sideBySideRow{}
+			if i < len(removed) {
+				row.left, row.hasLeft = removed[i], true
+			}
+			if i < len(added) {
+				row.right, row.hasRight = added[i], true
+			}
+			row.changed = row.hasLeft && row.hasRight
+			rows = append(rows, row)
+		}
+		removed, added = []string{}, []string{}
+	}
+
+	for _, op := range ops {
+		switch op.kind {
+		case '-':
+			removed = append(removed, op.text)
+		case '+':
+			added = append(added, op.text)
+		default:
+			flush()
+			rows = append(rows, sideBySideRow{
+				left:     op.text,
+				right:    op.text,
+				hasLeft:  true,
+				hasRight: true,
+			})
+		}
+	}
+	flush()
+	return rows
+}
+
+// terminalWidth is the width of stdout, or $COLUMNS, or 80.
+func terminalWidth() int {
+	if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+		return width
+	}
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	return 80
+}
+
+func wrapLine(line string, width int) []string {
+	if line == "" {
+		return []string{""}
+	}
+	parts := []string{}
+	runes := []rune(line)
+	for len(runes) > width {
+		parts = append(parts, string(runes[:width]))
+		runes = runes[width:]
+	}
+	return append(parts, string(runes))
+}
+
+func padRight(s string, width int) string {
+	return s + strings.Repeat(" ", max(width-utf8.RuneCountInString(s), 0))
+}
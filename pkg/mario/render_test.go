@@ -0,0 +1,67 @@
+package mario
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDiffHunkHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines1  string
+		lines2  string
+		context int
+		want    []string
+	}{
+		{
+			name:    "change with context",
+			lines1:  "a b c d",
+			lines2:  "a x c d",
+			context: 1,
+			want:    []string{"@@ -1,3 +1,3 @@"},
+		},
+		{
+			name:    "pure insertion",
+			lines1:  "a b c",
+			lines2:  "a b x c",
+			context: 0,
+			want:    []string{"@@ -2,0 +3,1 @@"},
+		},
+		{
+			name:    "pure deletion",
+			lines1:  "a b c",
+			lines2:  "a c",
+			context: 0,
+			want:    []string{"@@ -2,1 +1,0 @@"},
+		},
+		{
+			name:    "insertion at the start",
+			lines1:  "a b",
+			lines2:  "x a b",
+			context: 0,
+			want:    []string{"@@ -0,0 +1,1 @@"},
+		},
+		{
+			name:    "separate hunks",
+			lines1:  "1 2 3 4 5 6 7 8 9",
+			lines2:  "1 x 3 4 5 6 7 9",
+			context: 1,
+			want:    []string{"@@ -1,3 +1,3 @@", "@@ -7,3 +7,2 @@"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ops := diffLines(strings.Fields(test.lines1), strings.Fields(test.lines2))
+
+			headers := []string{}
+			for _, hunk := range diffHunks(ops, test.context) {
+				headers = append(headers, hunk.header())
+			}
+			if !slices.Equal(headers, test.want) {
+				t.Errorf("headers = %q, want %q", headers, test.want)
+			}
+		})
+	}
+}
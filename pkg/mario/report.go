@@ -0,0 +1,279 @@
+package mario
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// reportFormats are the values --report accepts. markdown is meant to be
+// posted as a pull request comment, github prints workflow commands that
+// GitHub Actions turns into annotations.
+var reportFormats = []string{"markdown", "github"}
+
+var reportFormat string
+
+// UseReport selects a report to print instead of the table or --output.
+// An empty format prints no report.
+func UseReport(format string) error {
+	if format == "" {
+		reportFormat = ""
+		return nil
+	}
+	if !slices.Contains(reportFormats, format) {
+		return fmt.Errorf(
+			"report %q is not one of %s",
+			format,
+			strings.Join(reportFormats, ", "),
+		)
+	}
+	reportFormat = format
+	timerOutput = os.Stderr
+	return nil
+}
+
+// annotation is one GitHub workflow command, such as
+// ::error file=pipeline.json,title=mario compare::removed activity gold
+type annotation struct {
+	level   string
+	file    string
+	title   string
+	message string
+}
+
+func (a annotation) String() string {
+	properties := []string{}
+	if a.file != "" {
+		properties = append(properties, "file="+escapeProperty(a.file))
+	}
+	if a.title != "" {
+		properties = append(properties, "title="+escapeProperty(a.title))
+	}
+
+	command := "::" + a.level
+	if len(properties) > 0 {
+		command += " " + strings.Join(properties, ",")
+	}
+	return command + "::" + escapeData(a.message)
+}
+
+// escapeData and escapeProperty encode the characters that would end a
+// workflow command early.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeProperty(s string) string {
+	return strings.NewReplacer(":", "%3A", ",", "%2C").Replace(escapeData(s))
+}
+
+// writeReport prints markdown for the markdown report, or annotations for the
+// github report. The github report also adds the markdown to the job summary
+// when running in GitHub Actions.
+func writeReport(markdown string, annotations []annotation) error {
+	if reportFormat == "markdown" {
+		_, err := fmt.Print(markdown)
+		return err
+	}
+
+	for _, a := range annotations {
+		if _, err := fmt.Println(a); err != nil {
+			return err
+		}
+	}
+
+	summaryPath := os.Getenv("GITHUB_STEP_SUMMARY")
+	if summaryPath == "" {
+		return nil
+	}
+	summary, err := os.OpenFile(summaryPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer summary.Close()
+	_, err = fmt.Fprint(summary, markdown)
+	return err
+}
+
+func writePipelineReport(
+	diff ResourceDiff,
+	resource1 map[string]interface{},
+	resource2 map[string]interface{},
+	ref1 PipelineRef,
+	ref2 PipelineRef,
+) error {
+	name1 := ref1.String()
+	name2 := ref2.String()
+
+	markdown := strings.Builder{}
+	if diff.equal() {
+		fmt.Fprintf(&markdown, "### ✔ `%s` matches `%s`\n\n", name1, name2)
+	} else {
+		fmt.Fprintf(&markdown, "### ✘ `%s` differs from `%s`\n\n", name1, name2)
+		columns, rows := recordRows(diff.records())
+		writeMarkdownTable(&markdown, columns, rows)
+		fmt.Fprintln(&markdown)
+	}
+	writeIgnoredMarkdown(&markdown, diff.Ignored)
+	if !diff.equal() {
+		writeDiffMarkdown(&markdown, "unified diff", unifiedDiffLines(resource1, resource2, name1, name2))
+	}
+
+	// annotate the local file, which is what a pull request changes
+	file := ref2.File
+	if file == "" {
+		file = ref1.File
+	}
+	title := fmt.Sprintf("mario compare %s %s", name1, name2)
+
+	annotations := []annotation{}
+	for _, message := range diffMessages(diff) {
+		annotations = append(annotations, annotation{
+			level:   "error",
+			file:    file,
+			title:   title,
+			message: message,
+		})
+	}
+	if diff.equal() {
+		annotations = append(annotations, annotation{
+			level:   "notice",
+			title:   title,
+			message: "no differences found",
+		})
+	}
+	return writeReport(markdown.String(), annotations)
+}
+
+// diffMessages describes each change of a diff on one line.
+func diffMessages(diff ResourceDiff) []string {
+	messages := []string{}
+	for _, change := range diff.Activities {
+		if change.Change != activityModified {
+			messages = append(messages, describeActivityChange(change))
+			continue
+		}
+		for _, difference := range change.Differences {
+			messages = append(messages, fmt.Sprintf(
+				"%s: %s: %s != %s",
+				describeActivityChange(change),
+				difference.Path,
+				difference.Value1,
+				difference.Value2,
+			))
+		}
+	}
+	for _, difference := range diff.Differences {
+		messages = append(messages, fmt.Sprintf(
+			"%s: %s != %s",
+			difference.Path,
+			difference.Value1,
+			difference.Value2,
+		))
+	}
+	return messages
+}
+
+func writeFactoryReport(comparison FactoryComparison, kinds []string) error {
+	from := comparison.From
+	to := comparison.To
+
+	markdown := strings.Builder{}
+	if comparison.drifted() {
+		fmt.Fprintf(&markdown, "### ✘ `%s` has drifted from `%s`\n\n", to, from)
+	} else {
+		fmt.Fprintf(&markdown, "### ✔ `%s` matches `%s`\n\n", to, from)
+	}
+
+	rows := [][]string{}
+	for _, kind := range kinds {
+		counts := comparison.statusCounts(kind)
+		rows = append(rows, []string{
+			kind,
+			fmt.Sprint(counts[resourceOnlyInFrom]),
+			fmt.Sprint(counts[resourceOnlyInTo]),
+			fmt.Sprint(counts[resourceDiffers]),
+			fmt.Sprint(counts[resourceMatches]),
+		})
+	}
+	writeMarkdownTable(
+		&markdown,
+		[]string{"kind", "only in " + from, "only in " + to, "differ", "match"},
+		rows,
+	)
+	fmt.Fprintln(&markdown)
+
+	annotations := []annotation{}
+	rows = [][]string{}
+	for _, resource := range comparison.Resources {
+		a := annotation{level: "error", title: fmt.Sprintf("mario compare factories %s %s", from, to)}
+		switch resource.Status {
+		case resourceOnlyInFrom:
+			rows = append(rows, []string{resource.Kind, resource.Name, "only in " + from, ""})
+			a.message = fmt.Sprintf("%s %s is only in %s", resource.Kind, resource.Name, from)
+		case resourceOnlyInTo:
+			rows = append(rows, []string{resource.Kind, resource.Name, "only in " + to, ""})
+			// a resource only in the target is not lost by promoting
+			a.level = "warning"
+			a.message = fmt.Sprintf("%s %s is only in %s", resource.Kind, resource.Name, to)
+		case resourceDiffers:
+			rows = append(rows, []string{
+				resource.Kind,
+				resource.Name,
+				"differs",
+				fmt.Sprint(resource.changes()),
+			})
+			a.message = fmt.Sprintf(
+				"%s %s differs: %s",
+				resource.Kind,
+				resource.Name,
+				strings.Join(diffMessages(resource.ResourceDiff), "\n"),
+			)
+		default:
+			continue
+		}
+		annotations = append(annotations, a)
+	}
+	if !comparison.drifted() {
+		annotations = append(annotations, annotation{
+			level:   "notice",
+			title:   fmt.Sprintf("mario compare factories %s %s", from, to),
+			message: "no drift found",
+		})
+	}
+	if len(rows) > 0 {
+		writeMarkdownTable(&markdown, []string{"kind", "name", "status", "differences"}, rows)
+		fmt.Fprintln(&markdown)
+	}
+	writeIgnoredMarkdown(&markdown, comparison.ignored())
+
+	for _, resource := range comparison.Resources {
+		if resource.Status != resourceDiffers {
+			continue
+		}
+		name1 := from + "/" + resource.Name
+		name2 := to + "/" + resource.Name
+		writeDiffMarkdown(
+			&markdown,
+			resource.Kind+"/"+resource.Name,
+			unifiedDiffLines(resource.resource1, resource.resource2, name1, name2),
+		)
+	}
+	return writeReport(markdown.String(), annotations)
+}
+
+func writeIgnoredMarkdown(w io.Writer, ignored []IgnoredDifference) {
+	if len(ignored) > 0 {
+		fmt.Fprintf(w, "_%s_\n\n", ignoredSummary(ignored))
+	}
+}
+
+// writeDiffMarkdown writes a unified diff in a collapsed section, since whole
+// pipelines make long comments.
+func writeDiffMarkdown(w io.Writer, summary string, lines []string) {
+	fmt.Fprintf(w, "<details>\n<summary>%s</summary>\n\n", summary)
+	fmt.Fprintf(w, "```diff\n%s\n```\n\n", strings.Join(lines, "\n"))
+	fmt.Fprint(w, "</details>\n\n")
+}
@@ -0,0 +1,215 @@
+package mario
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/datafactory/armdatafactory/v3"
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+)
+
+// RerunOptions picks the runs to start again. Either RunID names a single run,
+// or Failed selects every pipeline matching Name whose latest run in Query
+// failed.
+type RerunOptions struct {
+	RunID       string
+	Failed      bool
+	Name        string
+	Query       RunQuery
+	FromFailure bool
+	DryRun      bool
+	Yes         bool
+}
+
+type rerunTarget struct {
+	run              *armdatafactory.PipelineRun
+	failedActivities []string
+}
+
+// Rerun starts recovery runs that reference the original run, so they share
+// its parameters and run group. With FromFailure ADF skips the activities that
+// already succeeded and restarts from the ones that failed.
+func Rerun(options RerunOptions) {
+	defer timer("Rerun")()
+	factory := getFactoryClient()
+	ctx := context.Background()
+
+	runs := []*armdatafactory.PipelineRun{}
+	if options.RunID != "" {
+		run, err := factory.source.GetPipelineRun(ctx, options.RunID)
+		if err != nil {
+			log.Fatal(err)
+		}
+		runs = append(runs, &run)
+	} else {
+		runs = latestFailedRuns(&factory, ctx, options.Query, options.Name)
+	}
+
+	if len(runs) == 0 {
+		fmt.Println("No failed runs to rerun")
+		return
+	}
+
+	targets := []rerunTarget{}
+	for _, run := range runs {
+		target := rerunTarget{run: run}
+		if options.FromFailure {
+			target.failedActivities = failedActivityNames(&factory, ctx, *run)
+		}
+		targets = append(targets, target)
+	}
+
+	printRerunPlan(targets, options.FromFailure)
+
+	if options.DryRun {
+		fmt.Println("dry run, no runs started")
+		return
+	}
+	if !options.Yes && !confirm(fmt.Sprintf("Start %d run(s)?", len(targets))) {
+		fmt.Println("aborted")
+		return
+	}
+
+	newRunIDs := make([]string, len(targets))
+	errs := make([]error, len(targets))
+	for i, target := range targets {
+		newRunIDs[i], errs[i] = factory.source.CreateRun(
+			ctx,
+			stringValue(target.run.PipelineName),
+			rerunOptions(target.run, options.FromFailure),
+		)
+	}
+
+	failures := printRerunResults(targets, newRunIDs, errs)
+	if failures > 0 {
+		log.Fatalf("%d of %d reruns failed to start", failures, len(targets))
+	}
+}
+
+// latestFailedRuns returns the latest run of every matching pipeline when that
+// run failed. Older failures that were already rerun successfully are left
+// alone.
+func latestFailedRuns(
+	factory *Factory,
+	ctx context.Context,
+	query RunQuery,
+	name string,
+) []*armdatafactory.PipelineRun {
+	pipelineRuns, err := getPipelineRuns(factory, ctx, query, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	latestRuns := map[string]*armdatafactory.PipelineRun{}
+	for _, run := range pipelineRuns.Value {
+		pipelineName := stringValue(run.PipelineName)
+		if !strings.Contains(pipelineName, name) {
+			continue
+		}
+		latest, exists := latestRuns[pipelineName]
+		if !exists || compareTimes(latest.RunStart, run.RunStart) < 0 {
+			latestRuns[pipelineName] = run
+		}
+	}
+
+	failedRuns := []*armdatafactory.PipelineRun{}
+	for _, run := range latestRuns {
+		if stringValue(run.Status) == "Failed" {
+			failedRuns = append(failedRuns, run)
+		}
+	}
+	slices.SortFunc(failedRuns, func(a, b *armdatafactory.PipelineRun) int {
+		return strings.Compare(stringValue(a.PipelineName), stringValue(b.PipelineName))
+	})
+	return failedRuns
+}
+
+func failedActivityNames(
+	factory *Factory,
+	ctx context.Context,
+	run armdatafactory.PipelineRun,
+) []string {
+	activityRuns, err := getActivityRuns(factory, ctx, run)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	names := []string{}
+	for _, activityRun := range activityRuns {
+		if stringValue(activityRun.Status) == "Failed" {
+			names = append(names, stringValue(activityRun.ActivityName))
+		}
+	}
+	return names
+}
+
+func rerunOptions(
+	run *armdatafactory.PipelineRun,
+	fromFailure bool,
+) *armdatafactory.PipelinesClientCreateRunOptions {
+	isRecovery := true
+	return &armdatafactory.PipelinesClientCreateRunOptions{
+		ReferencePipelineRunID: run.RunID,
+		IsRecovery:             &isRecovery,
+		StartFromFailure:       &fromFailure,
+	}
+}
+
+func printRerunPlan(targets []rerunTarget, fromFailure bool) {
+	headerFmt := color.New(color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+	tbl := table.New("Pipeline", "Run ID", "Status", "Started", "Restart From")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	for _, target := range targets {
+		restartFrom := "start"
+		if fromFailure {
+			restartFrom = "failed activities"
+			if len(target.failedActivities) > 0 {
+				restartFrom = strings.Join(target.failedActivities, ", ")
+			}
+		}
+
+		status := stringValue(target.run.Status)
+		tbl.AddRow(
+			stringValue(target.run.PipelineName),
+			stringValue(target.run.RunID),
+			statusColor(status)(status),
+			formatRunTime(target.run.RunStart),
+			restartFrom,
+		)
+	}
+	tbl.Print()
+	fmt.Println()
+}
+
+func printRerunResults(targets []rerunTarget, newRunIDs []string, errs []error) int {
+	headerFmt := color.New(color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+	tbl := table.New("Pipeline", "Original Run", "New Run", "Result")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	failures := 0
+	for i, target := range targets {
+		result := successColor()("started")
+		if errs[i] != nil {
+			failures++
+			result = failureColor()(errs[i].Error())
+		}
+
+		tbl.AddRow(
+			stringValue(target.run.PipelineName),
+			stringValue(target.run.RunID),
+			newRunIDs[i],
+			result,
+		)
+	}
+	tbl.Print()
+	return failures
+}
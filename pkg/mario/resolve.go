@@ -0,0 +1,296 @@
+package mario
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+)
+
+var flagOverrides AZEnv
+
+// factorySetting is one value of a profile that can also be set by a flag or
+// an environment variable. The env vars match the ones the setup/mario_adf
+// scripts read.
+type factorySetting struct {
+	name     string
+	flag     string
+	env      string
+	required bool
+	get      func(AZEnv) string
+	set      func(*AZEnv, string)
+}
+
+var factorySettings = []factorySetting{
+	{
+		name:     "subscriptionId",
+		flag:     "subscription",
+		env:      "AZ_SUBSCRIPTION_ID",
+		required: true,
+		get:      func(azEnv AZEnv) string { return azEnv.SubscriptionID },
+		set:      func(azEnv *AZEnv, v string) { azEnv.SubscriptionID = v },
+	},
+	{
+		name:     "resourceGroup",
+		flag:     "resource-group",
+		env:      "AZ_RESOURCE_GROUP",
+		required: true,
+		get:      func(azEnv AZEnv) string { return azEnv.ResourceGroupName },
+		set:      func(azEnv *AZEnv, v string) { azEnv.ResourceGroupName = v },
+	},
+	{
+		name:     "factory",
+		flag:     "factory",
+		env:      "AZ_DATAFACTORY_NAME",
+		required: true,
+		get:      func(azEnv AZEnv) string { return azEnv.DataFactoryName },
+		set:      func(azEnv *AZEnv, v string) { azEnv.DataFactoryName = v },
+	},
+	{
+		name: "auth",
+		get:  func(azEnv AZEnv) string { return azEnv.Auth },
+		set:  func(azEnv *AZEnv, v string) { azEnv.Auth = v },
+	},
+	{
+		name: "defaults.days",
+		get: func(azEnv AZEnv) string {
+			if azEnv.Defaults.Days == 0 {
+				return ""
+			}
+			return strconv.Itoa(azEnv.Defaults.Days)
+		},
+		set: func(azEnv *AZEnv, v string) { azEnv.Defaults.Days, _ = strconv.Atoi(v) },
+	},
+	{
+		name: "defaults.timezone",
+		get:  func(azEnv AZEnv) string { return azEnv.Defaults.Timezone },
+		set:  func(azEnv *AZEnv, v string) { azEnv.Defaults.Timezone = v },
+	},
+}
+
+type resolvedSetting struct {
+	name   string
+	value  string
+	source string
+}
+
+// configLayer is one config file, labelled with its path.
+type configLayer struct {
+	source string
+	config marioConfig
+}
+
+// UseFactoryOverrides sets factory details from flags, which win over every
+// other layer.
+func UseFactoryOverrides(azEnv AZEnv) {
+	flagOverrides = azEnv
+}
+
+// loadConfigLayers reads the project config and then the user config, in
+// order of precedence.
+func loadConfigLayers() ([]configLayer, error) {
+	projectSource := configFile
+	if _, err := os.Stat(configFile); err != nil {
+		if _, err := os.Stat(legacyConfigFile); err == nil {
+			projectSource = legacyConfigFile
+		}
+	}
+
+	layers := []configLayer{}
+	for _, path := range []string{configFile, userConfigPath()} {
+		config, err := loadConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		source := path
+		if path == configFile {
+			source = projectSource
+		}
+		layers = append(layers, configLayer{source: source, config: config})
+	}
+	return layers, nil
+}
+
+// mergeConfigLayers combines the layers into one config. Profiles with the
+// same name are merged field by field, so a project profile only needs the
+// values that differ from the user profile. Ignore rules of every layer apply.
+func mergeConfigLayers(layers []configLayer) marioConfig {
+	merged := marioConfig{Profiles: map[string]AZEnv{}}
+	for i := len(layers) - 1; i >= 0; i-- {
+		config := layers[i].config
+		if config.Current != "" {
+			merged.Current = config.Current
+		}
+		merged.Compare.Ignore = append(merged.Compare.Ignore, config.Compare.Ignore...)
+		merged.SLA = mergeSLAs(merged.SLA, config.SLA)
+		for name, members := range config.Groups {
+			if merged.Groups == nil {
+				merged.Groups = map[string][]string{}
+			}
+			merged.Groups[name] = members
+		}
+		for name, azEnv := range config.Profiles {
+			mergedEnv := merged.Profiles[name]
+			for _, setting := range factorySettings {
+				if value := setting.get(azEnv); value != "" {
+					setting.set(&mergedEnv, value)
+				}
+			}
+			merged.Profiles[name] = mergedEnv
+		}
+	}
+	return merged
+}
+
+// resolveAZEnv resolves every factory setting of a profile from, in order,
+// flags, environment variables, the project config, the user config and
+// fallback. It returns the settings with where each value came from.
+func resolveAZEnv(profile string, fallback *AZEnv) (AZEnv, []resolvedSetting, error) {
+	layers, err := loadConfigLayers()
+	if err != nil {
+		return AZEnv{}, nil, err
+	}
+
+	name, _, err := mergeConfigLayers(layers).selectProfile(profile)
+	// which profile to use only matters when flags and env vars leave a
+	// required setting unset, as they can set everything in CI
+	if errors.Is(err, errNoProfileSelected) && overridesComplete() {
+		err = nil
+	}
+	if err != nil {
+		return AZEnv{}, nil, err
+	}
+
+	azEnv := AZEnv{}
+	settings := []resolvedSetting{}
+	for _, setting := range factorySettings {
+		resolved := resolvedSetting{name: setting.name, source: "unset"}
+
+		switch {
+		case setting.flag != "" && setting.get(flagOverrides) != "":
+			resolved.value = setting.get(flagOverrides)
+			resolved.source = "flag --" + setting.flag
+		case setting.env != "" && os.Getenv(setting.env) != "":
+			resolved.value = os.Getenv(setting.env)
+			resolved.source = "env " + setting.env
+		default:
+			for _, layer := range layers {
+				profile, exists := layer.config.Profiles[name]
+				if exists && setting.get(profile) != "" {
+					resolved.value = setting.get(profile)
+					resolved.source = fmt.Sprintf("%s (profile %s)", layer.source, name)
+					break
+				}
+			}
+			if resolved.value == "" && fallback != nil && setting.get(*fallback) != "" {
+				resolved.value = setting.get(*fallback)
+				resolved.source = "fake-adf default"
+			}
+		}
+
+		setting.set(&azEnv, resolved.value)
+		settings = append(settings, resolved)
+	}
+	return azEnv, settings, nil
+}
+
+// overridesComplete reports whether flags and env vars set every required
+// factory setting.
+func overridesComplete() bool {
+	for _, setting := range factorySettings {
+		if !setting.required {
+			continue
+		}
+		if setting.get(flagOverrides) == "" && os.Getenv(setting.env) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// readConfig resolves the factory to use and exits with the missing settings
+// and how to provide them when it is incomplete.
+func readConfig(profile string, fallback *AZEnv) AZEnv {
+	defer timer("readConfig")()
+	azEnv, err := resolveFactoryEnv(profile, fallback)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return azEnv
+}
+
+// resolveFactoryEnv is readConfig for callers that handle the error.
+func resolveFactoryEnv(profile string, fallback *AZEnv) (AZEnv, error) {
+	azEnv, settings, err := resolveAZEnv(profile, fallback)
+	if err != nil {
+		return AZEnv{}, err
+	}
+
+	missing := []string{}
+	for i, setting := range factorySettings {
+		if setting.required && settings[i].value == "" {
+			missing = append(missing, fmt.Sprintf(
+				"%s (--%s, %s or a profile)",
+				setting.name,
+				setting.flag,
+				setting.env,
+			))
+		}
+	}
+	if len(missing) > 0 {
+		return AZEnv{}, fmt.Errorf(
+			"missing factory settings, run `mario setup` or set: %s",
+			strings.Join(missing, ", "),
+		)
+	}
+	return azEnv, nil
+}
+
+// ShowResolvedConfig prints the settings a command would use and the layer
+// each value came from.
+func ShowResolvedConfig() {
+	_, settings, err := resolveAZEnv(profileName, endpointFallback())
+	if err != nil {
+		log.Fatal(err)
+	}
+	layers, err := loadConfigLayers()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	headerFmt := color.New(color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+	tbl := table.New("Setting", "Value", "Source")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	name, _, _ := mergeConfigLayers(layers).selectProfile(profileName)
+	profileSource := profileNameSource
+	if profileName == "" {
+		profileSource = "unset"
+		for _, layer := range layers {
+			if layer.config.Current != "" {
+				profileSource = layer.source + " current"
+				break
+			}
+		}
+		if profileSource == "unset" && name != "" {
+			profileSource = "only profile"
+		}
+	}
+	tbl.AddRow("profile", name, profileSource)
+
+	for i, setting := range settings {
+		source := setting.source
+		if setting.value == "" && factorySettings[i].required {
+			source = failureColor()(source)
+		}
+		tbl.AddRow(setting.name, setting.value, source)
+	}
+	tbl.Print()
+}
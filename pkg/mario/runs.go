@@ -0,0 +1,337 @@
+package mario
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/datafactory/armdatafactory/v3"
+)
+
+const (
+	// ADF keeps pipeline run history for 45 days
+	runRetention = 45 * 24 * time.Hour
+	// longer ranges are split into several queries of at most this length
+	maxQueryWindow = 30 * 24 * time.Hour
+)
+
+// RunQuery selects the pipeline runs a command works on. Every command that
+// reads runs takes one so the flags behave the same everywhere.
+//
+// From and To accept absolute timestamps (RFC 3339, "2006-01-02 15:04" or
+// "2006-01-02"), "now", "today", "yesterday", or relative offsets such as
+// "-2w", "-3d", "-12h" and "-30m". When From is empty the window starts Days
+// days before To. Timestamps without an offset are read in Timezone, which
+// defaults to the local timezone. Statuses, when set, limits the query to
+// runs in one of those states.
+type RunQuery struct {
+	Days     int
+	From     string
+	To       string
+	Timezone string
+	MaxRuns  int
+	Statuses []string
+}
+
+func (query RunQuery) location() (*time.Location, error) {
+	if query.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(query.Timezone)
+}
+
+// window resolves the query to an absolute [from, to] range.
+func (query RunQuery) window(now time.Time) (time.Time, time.Time, error) {
+	loc, err := query.location()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	now = now.In(loc)
+
+	to := now
+	if query.To != "" {
+		to, err = parseRunTime(query.To, now, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("--to: %w", err)
+		}
+	}
+
+	var from time.Time
+	switch {
+	case query.From != "":
+		from, err = parseRunTime(query.From, now, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("--from: %w", err)
+		}
+	case query.Days > 0:
+		from = to.AddDate(0, 0, -query.Days)
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("days must be greater than 0")
+	}
+
+	oldest := now.Add(-runRetention)
+	if !to.After(oldest) {
+		return time.Time{}, time.Time{}, fmt.Errorf(
+			"range ending %s is outside the %d-day run retention",
+			to.Format(time.RFC3339),
+			int(runRetention.Hours()/24),
+		)
+	}
+	if from.Before(oldest) {
+		progressLogger.Printf(
+			"ADF only keeps %d days of run history, starting at %s",
+			int(runRetention.Hours()/24),
+			oldest.Format(time.RFC3339),
+		)
+		from = oldest
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf(
+			"start of range %s is not before end %s",
+			from.Format(time.RFC3339),
+			to.Format(time.RFC3339),
+		)
+	}
+
+	return from, to, nil
+}
+
+var relativeTimeUnits = map[byte]time.Duration{
+	'm': time.Minute,
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+var absoluteTimeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func parseRunTime(value string, now time.Time, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	switch value {
+	case "now":
+		return now, nil
+	case "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+
+	if strings.HasPrefix(value, "-") && len(value) > 2 {
+		unit, found := relativeTimeUnits[value[len(value)-1]]
+		amount, err := strconv.Atoi(value[1 : len(value)-1])
+		if found && err == nil {
+			return now.Add(-time.Duration(amount) * unit), nil
+		}
+	}
+
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed.In(loc), nil
+	}
+	for _, layout := range absoluteTimeLayouts {
+		if parsed, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return parsed, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf(
+		"could not parse %q, expected a timestamp like 2024-03-01 06:00 or an offset like -2w",
+		value,
+	)
+}
+
+// splitWindow breaks [from, to] into consecutive windows no longer than
+// maxQueryWindow.
+func splitWindow(from time.Time, to time.Time) [][2]time.Time {
+	windows := [][2]time.Time{}
+	for start := from; start.Before(to); start = start.Add(maxQueryWindow) {
+		end := start.Add(maxQueryWindow)
+		if end.After(to) {
+			end = to
+		}
+		windows = append(windows, [2]time.Time{start, end})
+	}
+	return windows
+}
+
+func getPipelineRuns(
+	factory *Factory,
+	ctx context.Context,
+	query RunQuery,
+	name string,
+) (armdatafactory.PipelineRunsQueryResponse, error) {
+	defer timer("getPipelineRuns")()
+	pipelineRuns, err := fetchPipelineRuns(factory, ctx, query, name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return pipelineRuns, nil
+}
+
+// fetchPipelineRuns is getPipelineRuns for callers that handle the error.
+func fetchPipelineRuns(
+	factory *Factory,
+	ctx context.Context,
+	query RunQuery,
+	name string,
+) (armdatafactory.PipelineRunsQueryResponse, error) {
+	pipelineRuns := armdatafactory.PipelineRunsQueryResponse{
+		Value: []*armdatafactory.PipelineRun{},
+	}
+	runsFrom, runsTo, err := query.window(time.Now())
+	if err != nil {
+		return pipelineRuns, err
+	}
+	loc, _ := query.location()
+
+	filters := []*armdatafactory.RunQueryFilter{}
+	if name != "" {
+		operand := armdatafactory.RunQueryFilterOperandPipelineName
+		operator := armdatafactory.RunQueryFilterOperatorEquals
+		nameFilter := make([]*string, 1)
+		nameFilter[0] = &name
+		filters = append(filters, &armdatafactory.RunQueryFilter{
+			Operand:  &operand,
+			Operator: &operator,
+			Values:   nameFilter,
+		})
+	}
+
+	if len(query.Statuses) > 0 {
+		operand := armdatafactory.RunQueryFilterOperandStatus
+		operator := armdatafactory.RunQueryFilterOperatorIn
+		statusFilter := make([]*string, len(query.Statuses))
+		for i := range query.Statuses {
+			statusFilter[i] = &query.Statuses[i]
+		}
+		filters = append(filters, &armdatafactory.RunQueryFilter{
+			Operand:  &operand,
+			Operator: &operator,
+			Values:   statusFilter,
+		})
+	}
+
+	progressLogger.Printf(
+		"Getting pipeline runs from %s to %s",
+		runsFrom.Format("2006-01-02 15:04 MST"),
+		runsTo.Format("2006-01-02 15:04 MST"),
+	)
+
+	seen := map[string]bool{}
+
+	// runs are read newest first, so --max-runs keeps the most recent ones
+	newestFirst := []*armdatafactory.RunQueryOrderBy{{
+		OrderBy: to(armdatafactory.RunQueryOrderByFieldRunStart),
+		Order:   to(armdatafactory.RunQueryOrderDESC),
+	}}
+	windows := splitWindow(runsFrom, runsTo)
+	slices.Reverse(windows)
+
+	for _, window := range windows {
+		windowFrom, windowTo := window[0], window[1]
+		runFilterParameters := armdatafactory.RunFilterParameters{
+			LastUpdatedAfter:  &windowFrom,
+			LastUpdatedBefore: &windowTo,
+			Filters:           filters,
+			OrderBy:           newestFirst,
+		}
+
+		maxRuns := 0
+		if query.MaxRuns > 0 {
+			maxRuns = query.MaxRuns - len(pipelineRuns.Value)
+		}
+
+		windowRuns, err := queryAllPipelineRuns(
+			factory,
+			ctx,
+			runFilterParameters,
+			maxRuns,
+		)
+		if err != nil {
+			return pipelineRuns, err
+		}
+
+		// a run updated exactly on a window boundary is returned twice
+		for _, run := range windowRuns.Value {
+			if run.RunID != nil && seen[*run.RunID] {
+				continue
+			}
+			if run.RunID != nil {
+				seen[*run.RunID] = true
+			}
+			localizeRun(run, loc)
+			pipelineRuns.Value = append(pipelineRuns.Value, run)
+		}
+
+		if query.MaxRuns > 0 && len(pipelineRuns.Value) >= query.MaxRuns {
+			break
+		}
+	}
+
+	// returned oldest first, the order every command prints runs in
+	slices.Reverse(pipelineRuns.Value)
+	return pipelineRuns, nil
+}
+
+// localizeRun converts a run's timestamps to the query's timezone so every
+// command prints times the way they were asked for.
+func localizeRun(run *armdatafactory.PipelineRun, loc *time.Location) {
+	for _, t := range []*time.Time{run.RunStart, run.RunEnd, run.LastUpdated} {
+		if t != nil {
+			*t = t.In(loc)
+		}
+	}
+}
+
+// queryAllPipelineRuns follows continuation tokens until every page has been
+// read or maxRuns runs have been collected. maxRuns <= 0 means no cap.
+func queryAllPipelineRuns(
+	factory *Factory,
+	ctx context.Context,
+	filter armdatafactory.RunFilterParameters,
+	maxRuns int,
+) (armdatafactory.PipelineRunsQueryResponse, error) {
+	pipelineRuns := armdatafactory.PipelineRunsQueryResponse{
+		Value: []*armdatafactory.PipelineRun{},
+	}
+	defer fmt.Fprintln(progressLogger.Writer())
+
+	for page := 1; ; page++ {
+		response, err := factory.source.QueryPipelineRuns(ctx, filter)
+		if err != nil {
+			return pipelineRuns, err
+		}
+
+		pipelineRuns.Value = append(pipelineRuns.Value, response.Value...)
+		fmt.Fprintf(
+			progressLogger.Writer(),
+			"\rfetched %d pipeline runs (page %d)",
+			len(pipelineRuns.Value),
+			page,
+		)
+
+		if maxRuns > 0 && len(pipelineRuns.Value) >= maxRuns {
+			pipelineRuns.Value = pipelineRuns.Value[:maxRuns]
+			pipelineRuns.ContinuationToken = response.ContinuationToken
+			fmt.Fprintf(progressLogger.Writer(), ", stopped at --max-runs %d", maxRuns)
+			return pipelineRuns, nil
+		}
+
+		if response.ContinuationToken == nil || *response.ContinuationToken == "" {
+			return pipelineRuns, nil
+		}
+		filter.ContinuationToken = response.ContinuationToken
+	}
+}
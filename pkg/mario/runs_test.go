@@ -0,0 +1,276 @@
+package mario
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/datafactory/armdatafactory/v3"
+)
+
+const fixturesDir = "../../setup/fixtures"
+
+func TestWindow(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	days := func(n int) time.Duration { return time.Duration(n) * 24 * time.Hour }
+
+	tests := []struct {
+		name     string
+		query    RunQuery
+		wantFrom time.Time
+		wantTo   time.Time
+		wantErr  string
+	}{
+		{
+			name:     "days back from now",
+			query:    RunQuery{Days: 3, Timezone: "UTC"},
+			wantFrom: now.Add(-days(3)),
+			wantTo:   now,
+		},
+		{
+			name:     "offsets",
+			query:    RunQuery{From: "-2w", To: "-1w", Timezone: "UTC"},
+			wantFrom: now.Add(-days(14)),
+			wantTo:   now.Add(-days(7)),
+		},
+		{
+			name:     "timestamps in the query timezone",
+			query:    RunQuery{From: "2024-03-01", To: "2024-03-02T06:00", Timezone: "America/Chicago"},
+			wantFrom: time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC),
+			wantTo:   time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "from clamped to retention",
+			query:    RunQuery{From: "-60d", To: "-10d", Timezone: "UTC"},
+			wantFrom: now.Add(-runRetention),
+			wantTo:   now.Add(-days(10)),
+		},
+		{
+			name:     "days clamped to retention",
+			query:    RunQuery{Days: 90, Timezone: "UTC"},
+			wantFrom: now.Add(-runRetention),
+			wantTo:   now,
+		},
+		{
+			name:    "range outside retention",
+			query:   RunQuery{From: "-60d", To: "-50d", Timezone: "UTC"},
+			wantErr: "outside the 45-day run retention",
+		},
+		{
+			name:    "from after to",
+			query:   RunQuery{From: "-1d", To: "-2d", Timezone: "UTC"},
+			wantErr: "is not before end",
+		},
+		{
+			name:    "no days or from",
+			query:   RunQuery{Timezone: "UTC"},
+			wantErr: "days must be greater than 0",
+		},
+		{
+			name:    "unparseable from",
+			query:   RunQuery{From: "last week", Timezone: "UTC"},
+			wantErr: "--from: could not parse",
+		},
+		{
+			name:    "unknown timezone",
+			query:   RunQuery{Days: 1, Timezone: "Mars/Olympus"},
+			wantErr: "unknown time zone",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			from, to, err := test.query.window(now)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("window() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("window() error = %v", err)
+			}
+			if !from.Equal(test.wantFrom) || !to.Equal(test.wantTo) {
+				t.Errorf("window() = %s, %s, want %s, %s", from, to, test.wantFrom, test.wantTo)
+			}
+		})
+	}
+}
+
+func TestSplitWindow(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		to   time.Time
+		want [][2]time.Time
+	}{
+		{
+			name: "empty",
+			to:   from,
+			want: [][2]time.Time{},
+		},
+		{
+			name: "shorter than a query",
+			to:   from.Add(24 * time.Hour),
+			want: [][2]time.Time{{from, from.Add(24 * time.Hour)}},
+		},
+		{
+			name: "exactly one query",
+			to:   from.Add(maxQueryWindow),
+			want: [][2]time.Time{{from, from.Add(maxQueryWindow)}},
+		},
+		{
+			name: "retention",
+			to:   from.Add(runRetention),
+			want: [][2]time.Time{
+				{from, from.Add(maxQueryWindow)},
+				{from.Add(maxQueryWindow), from.Add(runRetention)},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := splitWindow(from, test.to)
+			if len(got) != len(test.want) {
+				t.Fatalf("splitWindow() = %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if !got[i][0].Equal(test.want[i][0]) || !got[i][1].Equal(test.want[i][1]) {
+					t.Errorf("window %d = %v, want %v", i, got[i], test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFetchPipelineRunsFixtures(t *testing.T) {
+	factory, err := fixtureFactory(fixturesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		query    RunQuery
+		pipeline string
+		want     int
+	}{
+		{name: "one day", query: RunQuery{Days: 1}, want: 8},
+		{name: "one week", query: RunQuery{Days: 7}, want: 56},
+		{name: "split across queries", query: RunQuery{Days: 45}, want: 166},
+		{name: "pipeline name", query: RunQuery{Days: 7}, pipeline: "mario_job", want: 28},
+		{name: "max runs", query: RunQuery{Days: 45, MaxRuns: 10}, want: 10},
+		{name: "status", query: RunQuery{Days: 45, Statuses: []string{"Queued", "InProgress"}}, want: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runs, err := fetchPipelineRuns(&factory, context.Background(), test.query, test.pipeline)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(runs.Value) != test.want {
+				t.Errorf("fetchPipelineRuns() returned %d runs, want %d", len(runs.Value), test.want)
+			}
+
+			seen := map[string]bool{}
+			for _, run := range runs.Value {
+				if seen[stringValue(run.RunID)] {
+					t.Errorf("run %s returned twice", stringValue(run.RunID))
+				}
+				seen[stringValue(run.RunID)] = true
+				if test.pipeline != "" && stringValue(run.PipelineName) != test.pipeline {
+					t.Errorf("run %s is of pipeline %s", stringValue(run.RunID), stringValue(run.PipelineName))
+				}
+			}
+		})
+	}
+}
+
+func TestQueryAllPipelineRunsPages(t *testing.T) {
+	tests := []struct {
+		name      string
+		pageSize  int
+		maxRuns   int
+		wantRuns  int
+		wantPages int32
+		wantMore  bool
+	}{
+		{name: "one page", pageSize: 500, wantRuns: 166, wantPages: 1},
+		{name: "default page size", pageSize: 0, wantRuns: 166, wantPages: 2},
+		{name: "small pages", pageSize: 7, wantRuns: 166, wantPages: 24},
+		{name: "max runs within a page", pageSize: 100, maxRuns: 10, wantRuns: 10, wantPages: 1, wantMore: true},
+		{name: "max runs across pages", pageSize: 7, maxRuns: 15, wantRuns: 15, wantPages: 3, wantMore: true},
+		{name: "max runs above total", pageSize: 50, maxRuns: 500, wantRuns: 166, wantPages: 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			factory, queries := serveFakeADF(t, test.pageSize)
+
+			from := time.Now().Add(-runRetention)
+			to := time.Now()
+			runs, err := queryAllPipelineRuns(
+				factory,
+				context.Background(),
+				armdatafactory.RunFilterParameters{LastUpdatedAfter: &from, LastUpdatedBefore: &to},
+				test.maxRuns,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(runs.Value) != test.wantRuns {
+				t.Errorf("got %d runs, want %d", len(runs.Value), test.wantRuns)
+			}
+			if got := queries.Load(); got != test.wantPages {
+				t.Errorf("read %d pages, want %d", got, test.wantPages)
+			}
+			if more := runs.ContinuationToken != nil; more != test.wantMore {
+				t.Errorf("continuation token set = %v, want %v", more, test.wantMore)
+			}
+
+			seen := map[string]bool{}
+			for _, run := range runs.Value {
+				if seen[stringValue(run.RunID)] {
+					t.Errorf("run %s returned twice", stringValue(run.RunID))
+				}
+				seen[stringValue(run.RunID)] = true
+			}
+		})
+	}
+}
+
+func TestFetchPipelineRunsKeepsNewest(t *testing.T) {
+	factory, err := fixtureFactory(fixturesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	all, err := fetchPipelineRuns(&factory, ctx, RunQuery{Days: 45}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(all.Value); i++ {
+		if all.Value[i].RunStart.Before(*all.Value[i-1].RunStart) {
+			t.Fatalf("run %d starts before the run returned ahead of it", i)
+		}
+	}
+
+	for _, maxRuns := range []int{1, 10, 100} {
+		capped, err := fetchPipelineRuns(&factory, ctx, RunQuery{Days: 45, MaxRuns: maxRuns}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		newest := all.Value[len(all.Value)-maxRuns:]
+		for i, run := range capped.Value {
+			if stringValue(run.RunID) != stringValue(newest[i].RunID) {
+				t.Fatalf("--max-runs %d returned %s at %d, want the newest runs", maxRuns, stringValue(run.RunID), i)
+			}
+		}
+	}
+}
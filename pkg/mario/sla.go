@@ -0,0 +1,538 @@
+package mario
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/datafactory/armdatafactory/v3"
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+)
+
+// PipelineSLA is a pipeline's service level in the config: a time of day
+// its data must be ready by, a longest acceptable run, or both.
+type PipelineSLA struct {
+	Pipeline string `yaml:"pipeline"`
+	// a local time like 06:00, read in Timezone
+	FinishBy string `yaml:"finishBy,omitempty"`
+	// a duration like 45m
+	MaxDuration string `yaml:"maxDuration,omitempty"`
+	// IANA timezone of FinishBy, defaults to --tz or the local timezone
+	Timezone string `yaml:"timezone,omitempty"`
+}
+
+// SLA check results, from the most to the least urgent
+const (
+	slaMissed  = "missed"
+	slaAtRisk  = "at-risk"
+	slaPending = "pending"
+	slaMet     = "met"
+)
+
+var slaStatuses = []string{slaMissed, slaAtRisk, slaPending, slaMet}
+
+// slaRule is a parsed PipelineSLA.
+type slaRule struct {
+	pipeline    string
+	finishBy    time.Duration
+	hasFinishBy bool
+	maxDuration time.Duration
+	location    *time.Location
+}
+
+// SLAResult is the outcome of one SLA for one deadline or run. EndTime is
+// when the run finished, or when it is expected to when Estimated is set.
+type SLAResult struct {
+	FactoryName  string    `json:"factoryName" yaml:"factoryName"`
+	PipelineName string    `json:"pipelineName" yaml:"pipelineName"`
+	SLA          string    `json:"sla" yaml:"sla"`
+	Status       string    `json:"status" yaml:"status"`
+	RunID        string    `json:"runId" yaml:"runId"`
+	Deadline     time.Time `json:"deadline" yaml:"deadline"`
+	EndTime      time.Time `json:"endTime" yaml:"endTime"`
+	Estimated    bool      `json:"estimated" yaml:"estimated"`
+	Detail       string    `json:"detail" yaml:"detail"`
+}
+
+// mergeSLAs adds the SLAs of a config layer, replacing any earlier SLA of the
+// same pipeline.
+func mergeSLAs(merged []PipelineSLA, layer []PipelineSLA) []PipelineSLA {
+	for _, sla := range layer {
+		i := slices.IndexFunc(merged, func(existing PipelineSLA) bool {
+			return existing.Pipeline == sla.Pipeline
+		})
+		if i >= 0 {
+			merged[i] = sla
+		} else {
+			merged = append(merged, sla)
+		}
+	}
+	return merged
+}
+
+func parseSLA(sla PipelineSLA, defaultLocation *time.Location) (slaRule, error) {
+	rule := slaRule{pipeline: sla.Pipeline, location: defaultLocation}
+	if sla.Pipeline == "" {
+		return rule, errors.New("sla: pipeline is required")
+	}
+	if sla.FinishBy == "" && sla.MaxDuration == "" {
+		return rule, fmt.Errorf("sla %s: set finishBy, maxDuration or both", sla.Pipeline)
+	}
+
+	if sla.FinishBy != "" {
+		clock, err := time.Parse("15:04", sla.FinishBy)
+		if err != nil {
+			return rule, fmt.Errorf("sla %s: finishBy %q is not a time like 06:00", sla.Pipeline, sla.FinishBy)
+		}
+		rule.finishBy = time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute
+		rule.hasFinishBy = true
+	}
+	if sla.MaxDuration != "" {
+		maxDuration, err := time.ParseDuration(sla.MaxDuration)
+		if err != nil || maxDuration <= 0 {
+			return rule, fmt.Errorf("sla %s: maxDuration %q is not a duration like 45m", sla.Pipeline, sla.MaxDuration)
+		}
+		rule.maxDuration = maxDuration
+	}
+	if sla.Timezone != "" {
+		location, err := time.LoadLocation(sla.Timezone)
+		if err != nil {
+			return rule, fmt.Errorf("sla %s: %w", sla.Pipeline, err)
+		}
+		rule.location = location
+	}
+	return rule, nil
+}
+
+// loadSLARules returns the SLAs merged across the config files.
+func loadSLARules(defaultLocation *time.Location) ([]slaRule, error) {
+	layers, err := loadConfigLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []slaRule{}
+	for _, sla := range mergeConfigLayers(layers).SLA {
+		rule, err := parseSLA(sla, defaultLocation)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// CheckSLAs evaluates the runs in query against the SLAs in the config and
+// reports whether any was missed. In progress runs are expected to take the
+// median of their pipeline's successful runs over the last historyDays. An
+// error means the SLAs could not be checked.
+func CheckSLAs(query RunQuery, historyDays int) (bool, error) {
+	defer timer("CheckSLAs")()
+	location, err := query.location()
+	if err != nil {
+		return false, err
+	}
+	rules, err := loadSLARules(location)
+	if err != nil {
+		return false, err
+	}
+	if len(rules) == 0 {
+		return false, fmt.Errorf("no slas in %s or %s", configFile, userConfigPath())
+	}
+
+	now := time.Now()
+	windowFrom, windowTo, err := query.window(now)
+	if err != nil {
+		return false, err
+	}
+	// a deadline early in the window can be met by a run started the day
+	// before it, and whether the deadline before that was missed decides
+	// which of those runs are late attempts at it
+	runsQuery := query
+	runsQuery.From = windowFrom.Add(-48 * time.Hour).Format(time.RFC3339)
+	runsQuery.To = windowTo.Format(time.RFC3339)
+
+	factories, err := factoryClients()
+	if err != nil {
+		return false, err
+	}
+	ctx := context.Background()
+	type factoryResult struct {
+		results []SLAResult
+		err     error
+	}
+	factoryResults := queryFactories(factories, func(factory *Factory) factoryResult {
+		pipelineRuns, err := fetchPipelineRuns(factory, ctx, runsQuery, "")
+		if err != nil {
+			return factoryResult{err: err}
+		}
+		expected, err := expectedDurations(factory, ctx, historyDays, "")
+		if err != nil {
+			return factoryResult{err: err}
+		}
+
+		results := []SLAResult{}
+		for _, rule := range rules {
+			runs := []*armdatafactory.PipelineRun{}
+			for _, run := range pipelineRuns.Value {
+				if stringValue(run.PipelineName) == rule.pipeline && run.RunStart != nil {
+					runs = append(runs, run)
+				}
+			}
+			slices.SortFunc(runs, func(a, b *armdatafactory.PipelineRun) int {
+				return a.RunStart.Compare(*b.RunStart)
+			})
+
+			expectedDuration, hasHistory := expected[rule.pipeline]
+			estimate := func(run *armdatafactory.PipelineRun) (time.Time, bool) {
+				if !hasHistory {
+					return time.Time{}, false
+				}
+				// a run already slower than usual finishes no earlier than now
+				return maxTime(run.RunStart.Add(expectedDuration), now), true
+			}
+
+			if rule.hasFinishBy {
+				results = append(results, checkFinishBy(rule, runs, windowFrom, now, estimate)...)
+			}
+			if rule.maxDuration > 0 {
+				results = append(results, checkMaxDuration(rule, runs, windowFrom, now, estimate)...)
+			}
+		}
+		for i := range results {
+			results[i].FactoryName = factory.factoryName
+		}
+		return factoryResult{results: results}
+	})
+
+	// a factory that cannot be read could hide a miss, so unlike the
+	// summaries the check stops rather than skipping it
+	results := []SLAResult{}
+	for i, factoryResult := range factoryResults {
+		if factoryResult.err != nil {
+			return false, fmt.Errorf("%s: %w", factories[i].label(), factoryResult.err)
+		}
+		results = append(results, factoryResult.results...)
+	}
+	slices.SortStableFunc(results, func(a, b SLAResult) int {
+		return cmp.Or(
+			cmp.Compare(slices.Index(slaStatuses, a.Status), slices.Index(slaStatuses, b.Status)),
+			strings.Compare(a.FactoryName, b.FactoryName),
+			strings.Compare(a.PipelineName, b.PipelineName),
+		)
+	})
+
+	if !tableOutput() {
+		if err := writeRecords(results); err != nil {
+			return false, err
+		}
+	} else {
+		printSLAResults(results, location, len(factories) > 1)
+	}
+
+	return slices.ContainsFunc(results, func(result SLAResult) bool {
+		return result.Status == slaMissed
+	}), nil
+}
+
+// checkFinishBy evaluates every deadline from the start of the window up to
+// the next one after now. A deadline is met by a successful run that ended
+// before it; runs belong to the first deadline at or after their start,
+// except that runs started after a missed deadline and closer to it than to
+// the next one are late attempts at the missed deadline.
+func checkFinishBy(
+	rule slaRule,
+	runs []*armdatafactory.PipelineRun,
+	windowFrom time.Time,
+	now time.Time,
+	estimate func(run *armdatafactory.PipelineRun) (time.Time, bool),
+) []SLAResult {
+	zone := rule.location.String()
+	if rule.location == time.Local {
+		zone = "local"
+	}
+	label := fmt.Sprintf(
+		"finish by %02d:%02d %s",
+		int(rule.finishBy.Hours()),
+		int(rule.finishBy.Minutes())%60,
+		zone,
+	)
+
+	results := []SLAResult{}
+	lastDeadline := rule.nextDeadline(now)
+	// runs started up to claimedUntil were taken by a missed deadline
+	var claimedUntil time.Time
+	// the deadline before the window is only evaluated for the runs it claims
+	firstDeadline := rule.previousDeadline(rule.nextDeadline(windowFrom))
+	for deadline := firstDeadline; !deadline.After(lastDeadline); deadline = rule.nextDeadline(deadline.Add(time.Minute)) {
+		periodStart := maxTime(rule.previousDeadline(deadline), claimedUntil)
+		deadlineRuns := runsStartedIn(runs, periodStart, deadline)
+		passed := now.After(deadline)
+
+		// the run that decides the result: a success on time, else the
+		// first late success, else the latest attempt
+		var decisive *armdatafactory.PipelineRun
+		for _, run := range deadlineRuns {
+			if stringValue(run.Status) == "Succeeded" && run.RunEnd != nil && !run.RunEnd.After(deadline) {
+				decisive = run
+				break
+			}
+		}
+		if decisive == nil && passed {
+			next := rule.nextDeadline(deadline.Add(time.Minute))
+			claimedUntil = deadline.Add(next.Sub(deadline) / 2)
+			deadlineRuns = append(deadlineRuns, runsStartedIn(runs, deadline, claimedUntil)...)
+		}
+		if deadline.Before(windowFrom) {
+			continue
+		}
+
+		result := SLAResult{
+			PipelineName: rule.pipeline,
+			SLA:          label,
+			Deadline:     deadline,
+		}
+		if decisive != nil {
+			result.Status = slaMet
+			result.RunID = stringValue(decisive.RunID)
+			result.EndTime = *decisive.RunEnd
+			results = append(results, result)
+			continue
+		}
+		for _, run := range deadlineRuns {
+			if stringValue(run.Status) == "Succeeded" && run.RunEnd != nil {
+				decisive = run
+				break
+			}
+		}
+		if decisive == nil && len(deadlineRuns) > 0 {
+			decisive = deadlineRuns[len(deadlineRuns)-1]
+		}
+		if decisive != nil {
+			result.RunID = stringValue(decisive.RunID)
+		}
+
+		switch {
+		case decisive == nil && passed:
+			result.Status = slaMissed
+			result.Detail = "no run"
+		case decisive == nil:
+			result.Status = slaPending
+			result.Detail = "no run yet"
+		case isRunActive(stringValue(decisive.Status)):
+			end, estimated := estimate(decisive)
+			result.EndTime, result.Estimated = end, estimated
+			switch {
+			case passed:
+				result.Status = slaMissed
+				result.Detail = "still running"
+			case !estimated:
+				result.Status = slaPending
+				result.Detail = "no successful runs to estimate from"
+			case end.After(deadline):
+				result.Status = slaAtRisk
+				result.Detail = fmt.Sprintf("expected %s late", end.Sub(deadline).Truncate(time.Minute))
+			default:
+				result.Status = slaPending
+				result.Detail = "on track"
+			}
+		case stringValue(decisive.Status) == "Succeeded":
+			result.Status = slaMissed
+			result.EndTime = *decisive.RunEnd
+			result.Detail = fmt.Sprintf("finished %s late", decisive.RunEnd.Sub(deadline).Truncate(time.Minute))
+		default:
+			// failed or cancelled, a rerun can still make an upcoming deadline
+			result.Status = slaAtRisk
+			if passed {
+				result.Status = slaMissed
+			}
+			result.Detail = strings.ToLower(stringValue(decisive.Status))
+			if decisive.RunEnd != nil {
+				result.EndTime = *decisive.RunEnd
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// checkMaxDuration evaluates every run started in the window.
+func checkMaxDuration(
+	rule slaRule,
+	runs []*armdatafactory.PipelineRun,
+	windowFrom time.Time,
+	now time.Time,
+	estimate func(run *armdatafactory.PipelineRun) (time.Time, bool),
+) []SLAResult {
+	label := "max " + rule.maxDuration.String()
+
+	results := []SLAResult{}
+	for _, run := range runs {
+		if run.RunStart.Before(windowFrom) {
+			continue
+		}
+		result := SLAResult{
+			PipelineName: rule.pipeline,
+			SLA:          label,
+			RunID:        stringValue(run.RunID),
+			Deadline:     run.RunStart.Add(rule.maxDuration),
+		}
+
+		if !isRunActive(stringValue(run.Status)) {
+			if run.RunEnd == nil {
+				continue
+			}
+			result.EndTime = *run.RunEnd
+			took := run.RunEnd.Sub(*run.RunStart)
+			result.Status = slaMet
+			if took > rule.maxDuration {
+				result.Status = slaMissed
+				result.Detail = fmt.Sprintf("took %s", took.Truncate(time.Second))
+			}
+			results = append(results, result)
+			continue
+		}
+
+		end, estimated := estimate(run)
+		result.EndTime, result.Estimated = end, estimated
+		switch {
+		case now.After(result.Deadline):
+			result.Status = slaMissed
+			result.Detail = fmt.Sprintf("running for %s", now.Sub(*run.RunStart).Truncate(time.Second))
+		case !estimated:
+			result.Status = slaPending
+			result.Detail = "no successful runs to estimate from"
+		case end.After(result.Deadline):
+			result.Status = slaAtRisk
+			result.Detail = fmt.Sprintf("expected to take %s", end.Sub(*run.RunStart).Truncate(time.Second))
+		default:
+			result.Status = slaPending
+			result.Detail = "on track"
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// runsStartedIn returns the runs started after from and up to until, in
+// start order.
+func runsStartedIn(runs []*armdatafactory.PipelineRun, from time.Time, until time.Time) []*armdatafactory.PipelineRun {
+	started := []*armdatafactory.PipelineRun{}
+	for _, run := range runs {
+		if run.RunStart.After(from) && !run.RunStart.After(until) {
+			started = append(started, run)
+		}
+	}
+	return started
+}
+
+// nextDeadline is the first finishBy time at or after t.
+func (rule slaRule) nextDeadline(t time.Time) time.Time {
+	hour := int(rule.finishBy.Hours())
+	minute := int(rule.finishBy.Minutes()) % 60
+
+	year, month, day := t.In(rule.location).Date()
+	deadline := time.Date(year, month, day, hour, minute, 0, 0, rule.location)
+	if deadline.Before(t) {
+		deadline = time.Date(year, month, day+1, hour, minute, 0, 0, rule.location)
+	}
+	return deadline
+}
+
+// previousDeadline is the last finishBy time before t.
+func (rule slaRule) previousDeadline(t time.Time) time.Time {
+	hour := int(rule.finishBy.Hours())
+	minute := int(rule.finishBy.Minutes()) % 60
+
+	year, month, day := rule.nextDeadline(t).Date()
+	return time.Date(year, month, day-1, hour, minute, 0, 0, rule.location)
+}
+
+func maxTime(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func printSLAResults(results []SLAResult, location *time.Location, byFactory bool) {
+	defer timer("printSLAResults")()
+	headerLength := 80
+
+	header := createHeader(
+		"SLA",
+		headerLength,
+		color.New(color.FgBlue),
+		"=",
+		true,
+	)
+	footer := createHeader("", headerLength, color.New(color.FgWhite), "=", true)
+
+	fmt.Print("\n", header, "\n")
+
+	if len(results) == 0 {
+		fmt.Println("No runs or deadlines found for the configured SLAs")
+		fmt.Println(footer)
+		return
+	}
+
+	counts := map[string]int{}
+	for _, result := range results {
+		counts[result.Status]++
+	}
+	statusLabels := map[string]string{
+		slaMissed:  failureColor()(slaMissed),
+		slaAtRisk:  color.New(color.FgYellow).Sprint(slaAtRisk),
+		slaPending: neutralColor()(slaPending),
+		slaMet:     successColor()(slaMet),
+	}
+	summary := []string{}
+	for _, status := range slaStatuses {
+		summary = append(summary, fmt.Sprintf("%s %d", statusLabels[status], counts[status]))
+	}
+	fmt.Println(strings.Join(summary, "  "))
+	fmt.Println()
+
+	headerFmt := color.New(color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+	columns := []interface{}{"Pipeline", "SLA", "Status", "Deadline", "End", "Detail"}
+	if byFactory {
+		columns = append([]interface{}{"Factory"}, columns...)
+	}
+	tbl := table.New(columns...)
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.In(location).Format("2006-01-02 15:04")
+	}
+	for _, result := range results {
+		end := formatTime(result.EndTime)
+		if result.Estimated {
+			end = "~" + end
+		}
+
+		row := []interface{}{
+			result.PipelineName,
+			result.SLA,
+			statusLabels[result.Status],
+			formatTime(result.Deadline),
+			end,
+			result.Detail,
+		}
+		if byFactory {
+			row = append([]interface{}{result.FactoryName}, row...)
+		}
+		tbl.AddRow(row...)
+	}
+	tbl.Print()
+	fmt.Println(footer)
+}
@@ -0,0 +1,216 @@
+package mario
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/datafactory/armdatafactory/v3"
+)
+
+// slaRun is a run of the pipeline under test, started and ended at offsets
+// from midnight UTC on 2024-03-01. An end of 0 means the run has not ended.
+func slaRun(runID string, status string, start time.Duration, end time.Duration) *armdatafactory.PipelineRun {
+	midnight := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	run := &armdatafactory.PipelineRun{
+		RunID:        to(runID),
+		PipelineName: to("mario_job"),
+		Status:       to(status),
+		RunStart:     to(midnight.Add(start)),
+	}
+	if end != 0 {
+		run.RunEnd = to(midnight.Add(end))
+	}
+	return run
+}
+
+func TestCheckFinishBy(t *testing.T) {
+	h, m := time.Hour, time.Minute
+	midnight := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type want struct {
+		deadline time.Duration
+		status   string
+		runID    string
+		detail   string
+	}
+
+	tests := []struct {
+		name     string
+		location *time.Location
+		runs     []*armdatafactory.PipelineRun
+		now      time.Duration
+		// the expected end of a run in progress, none when 0
+		expected time.Duration
+		want     []want
+	}{
+		{
+			name: "met",
+			runs: []*armdatafactory.PipelineRun{slaRun("a", "Succeeded", 5*h, 5*h+30*m)},
+			now:  12 * h,
+			want: []want{
+				{deadline: 6 * h, status: slaMet, runID: "a"},
+				{deadline: 30 * h, status: slaPending, detail: "no run yet"},
+			},
+		},
+		{
+			name: "rerun just after a missed deadline is late for it",
+			runs: []*armdatafactory.PipelineRun{
+				slaRun("a", "Failed", 5*h, 5*h+30*m),
+				slaRun("b", "Succeeded", 6*h+1*m, 6*h+30*m),
+			},
+			now: 12 * h,
+			want: []want{
+				{deadline: 6 * h, status: slaMissed, runID: "b", detail: "finished 30m0s late"},
+				{deadline: 30 * h, status: slaPending, detail: "no run yet"},
+			},
+		},
+		{
+			name: "first run just after a missed deadline is late for it",
+			runs: []*armdatafactory.PipelineRun{slaRun("a", "Succeeded", 6*h+1*m, 6*h+45*m)},
+			now:  12 * h,
+			want: []want{
+				{deadline: 6 * h, status: slaMissed, runID: "a", detail: "finished 45m0s late"},
+				{deadline: 30 * h, status: slaPending, detail: "no run yet"},
+			},
+		},
+		{
+			name: "run closer to the next deadline belongs to it",
+			runs: []*armdatafactory.PipelineRun{slaRun("a", "Succeeded", 20*h, 20*h+30*m)},
+			now:  36 * h,
+			want: []want{
+				{deadline: 6 * h, status: slaMissed, detail: "no run"},
+				{deadline: 30 * h, status: slaMet, runID: "a"},
+				{deadline: 54 * h, status: slaPending, detail: "no run yet"},
+			},
+		},
+		{
+			name: "late run is not counted again for the next deadline",
+			runs: []*armdatafactory.PipelineRun{
+				slaRun("a", "Succeeded", 6*h+5*m, 6*h+30*m),
+				slaRun("b", "Succeeded", 29*h, 29*h+30*m),
+			},
+			now: 36 * h,
+			want: []want{
+				{deadline: 6 * h, status: slaMissed, runID: "a", detail: "finished 30m0s late"},
+				{deadline: 30 * h, status: slaMet, runID: "b"},
+				{deadline: 54 * h, status: slaPending, detail: "no run yet"},
+			},
+		},
+		{
+			name: "runs from before the deadline's period do not count",
+			runs: []*armdatafactory.PipelineRun{slaRun("a", "Succeeded", -28*h, -27*h)},
+			now:  12 * h,
+			want: []want{
+				{deadline: 6 * h, status: slaMissed, detail: "no run"},
+				{deadline: 30 * h, status: slaPending, detail: "no run yet"},
+			},
+		},
+		{
+			name: "late run for the deadline before the window",
+			runs: []*armdatafactory.PipelineRun{
+				slaRun("a", "Succeeded", -17*h, -16*h),
+				slaRun("b", "Succeeded", -1*h, 1*h),
+			},
+			now: 12 * h,
+			want: []want{
+				{deadline: 6 * h, status: slaMet, runID: "b"},
+				{deadline: 30 * h, status: slaPending, detail: "no run yet"},
+			},
+		},
+		{
+			name: "still running after the deadline",
+			runs: []*armdatafactory.PipelineRun{slaRun("a", "InProgress", 5*h, 0)},
+			now:  7 * h,
+			want: []want{
+				{deadline: 6 * h, status: slaMissed, runID: "a", detail: "still running"},
+				{deadline: 30 * h, status: slaPending, detail: "no run yet"},
+			},
+		},
+		{
+			name:     "expected to finish late",
+			runs:     []*armdatafactory.PipelineRun{slaRun("a", "InProgress", 5*h, 0)},
+			now:      5*h + 10*m,
+			expected: 6*h + 30*m,
+			want: []want{
+				{deadline: 6 * h, status: slaAtRisk, runID: "a", detail: "expected 30m0s late"},
+			},
+		},
+		{
+			name:     "on track",
+			runs:     []*armdatafactory.PipelineRun{slaRun("a", "InProgress", 5*h, 0)},
+			now:      5*h + 10*m,
+			expected: 5*h + 40*m,
+			want: []want{
+				{deadline: 6 * h, status: slaPending, runID: "a", detail: "on track"},
+			},
+		},
+		{
+			name: "failed before the deadline",
+			runs: []*armdatafactory.PipelineRun{slaRun("a", "Failed", 4*h, 4*h+30*m)},
+			now:  5 * h,
+			want: []want{
+				{deadline: 6 * h, status: slaAtRisk, runID: "a", detail: "failed"},
+			},
+		},
+		{
+			name:     "deadline in the rule's timezone",
+			location: chicago,
+			runs:     []*armdatafactory.PipelineRun{slaRun("a", "Succeeded", 11*h, 11*h+30*m)},
+			now:      13 * h,
+			want: []want{
+				{deadline: 12 * h, status: slaMet, runID: "a"},
+				{deadline: 36 * h, status: slaPending, detail: "no run yet"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			location := test.location
+			if location == nil {
+				location = time.UTC
+			}
+			rule := slaRule{
+				pipeline:    "mario_job",
+				finishBy:    6 * time.Hour,
+				hasFinishBy: true,
+				location:    location,
+			}
+			estimate := func(run *armdatafactory.PipelineRun) (time.Time, bool) {
+				if test.expected == 0 {
+					return time.Time{}, false
+				}
+				return midnight.Add(test.expected), true
+			}
+
+			results := checkFinishBy(rule, test.runs, midnight, midnight.Add(test.now), estimate)
+			if len(results) != len(test.want) {
+				t.Fatalf("checkFinishBy() returned %d results, want %d: %+v", len(results), len(test.want), results)
+			}
+			for i, result := range results {
+				want := test.want[i]
+				if !result.Deadline.Equal(midnight.Add(want.deadline)) ||
+					result.Status != want.status ||
+					result.RunID != want.runID ||
+					result.Detail != want.detail {
+					t.Errorf(
+						"result %d = %s %s %q %q, want %s %s %q %q",
+						i,
+						result.Deadline.UTC(),
+						result.Status,
+						result.RunID,
+						result.Detail,
+						midnight.Add(want.deadline),
+						want.status,
+						want.runID,
+						want.detail,
+					)
+				}
+			}
+		})
+	}
+}
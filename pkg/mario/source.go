@@ -0,0 +1,611 @@
+package mario
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/datafactory/armdatafactory/v3"
+)
+
+var (
+	fixtureDirs []string
+
+	errFixturesReadOnly = errors.New(
+		"fixture factories are read-only, use `mario dev fake-adf` to start or cancel runs locally",
+	)
+)
+
+// RunSource is the backend mario reads pipelines, pipeline runs and activity
+// runs from, and starts runs through. The ARM implementation talks to a live
+// factory, the fixture implementation reads JSON files from disk and is
+// read-only.
+type RunSource interface {
+	ListPipelines(ctx context.Context) ([]*armdatafactory.PipelineResource, error)
+	GetPipeline(
+		ctx context.Context,
+		name string,
+	) (armdatafactory.PipelineResource, error)
+	GetPipelineRun(
+		ctx context.Context,
+		runID string,
+	) (armdatafactory.PipelineRun, error)
+	QueryPipelineRuns(
+		ctx context.Context,
+		filter armdatafactory.RunFilterParameters,
+	) (armdatafactory.PipelineRunsQueryResponse, error)
+	QueryActivityRuns(
+		ctx context.Context,
+		runID string,
+		filter armdatafactory.RunFilterParameters,
+	) (armdatafactory.ActivityRunsQueryResponse, error)
+	CreateRun(
+		ctx context.Context,
+		pipelineName string,
+		options *armdatafactory.PipelinesClientCreateRunOptions,
+	) (string, error)
+	CancelRun(ctx context.Context, runID string, recursive bool) error
+	ListDatasets(ctx context.Context) ([]*armdatafactory.DatasetResource, error)
+	ListLinkedServices(ctx context.Context) ([]*armdatafactory.LinkedServiceResource, error)
+	ListTriggers(ctx context.Context) ([]*armdatafactory.TriggerResource, error)
+}
+
+// UseFixtures points every command at fixture directories instead of Azure.
+// Commands that read a single factory use the first one, commands that
+// aggregate factories read each of them. No dirs restores the ARM backend.
+func UseFixtures(dirs []string) {
+	fixtureDirs = dirs
+}
+
+type armRunSource struct {
+	client            *armdatafactory.ClientFactory
+	resourceGroupName string
+	factoryName       string
+}
+
+func (s armRunSource) ListPipelines(
+	ctx context.Context,
+) ([]*armdatafactory.PipelineResource, error) {
+	pager := s.client.NewPipelinesClient().NewListByFactoryPager(
+		s.resourceGroupName,
+		s.factoryName,
+		nil,
+	)
+
+	pipelines := []*armdatafactory.PipelineResource{}
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		pipelines = append(pipelines, page.Value...)
+	}
+	return pipelines, nil
+}
+
+func (s armRunSource) GetPipeline(
+	ctx context.Context,
+	name string,
+) (armdatafactory.PipelineResource, error) {
+	pipeline, err := s.client.NewPipelinesClient().Get(
+		ctx,
+		s.resourceGroupName,
+		s.factoryName,
+		name,
+		nil,
+	)
+	return pipeline.PipelineResource, err
+}
+
+func (s armRunSource) GetPipelineRun(
+	ctx context.Context,
+	runID string,
+) (armdatafactory.PipelineRun, error) {
+	run, err := s.client.NewPipelineRunsClient().Get(
+		ctx,
+		s.resourceGroupName,
+		s.factoryName,
+		runID,
+		nil,
+	)
+	return run.PipelineRun, err
+}
+
+func (s armRunSource) QueryPipelineRuns(
+	ctx context.Context,
+	filter armdatafactory.RunFilterParameters,
+) (armdatafactory.PipelineRunsQueryResponse, error) {
+	runs, err := s.client.NewPipelineRunsClient().QueryByFactory(
+		ctx,
+		s.resourceGroupName,
+		s.factoryName,
+		filter,
+		nil,
+	)
+	return runs.PipelineRunsQueryResponse, err
+}
+
+func (s armRunSource) QueryActivityRuns(
+	ctx context.Context,
+	runID string,
+	filter armdatafactory.RunFilterParameters,
+) (armdatafactory.ActivityRunsQueryResponse, error) {
+	runs, err := s.client.NewActivityRunsClient().QueryByPipelineRun(
+		ctx,
+		s.resourceGroupName,
+		s.factoryName,
+		runID,
+		filter,
+		nil,
+	)
+	return runs.ActivityRunsQueryResponse, err
+}
+
+func (s armRunSource) CreateRun(
+	ctx context.Context,
+	pipelineName string,
+	options *armdatafactory.PipelinesClientCreateRunOptions,
+) (string, error) {
+	response, err := s.client.NewPipelinesClient().CreateRun(
+		ctx,
+		s.resourceGroupName,
+		s.factoryName,
+		pipelineName,
+		options,
+	)
+	return stringValue(response.RunID), err
+}
+
+func (s armRunSource) CancelRun(
+	ctx context.Context,
+	runID string,
+	recursive bool,
+) error {
+	_, err := s.client.NewPipelineRunsClient().Cancel(
+		ctx,
+		s.resourceGroupName,
+		s.factoryName,
+		runID,
+		&armdatafactory.PipelineRunsClientCancelOptions{IsRecursive: &recursive},
+	)
+	return err
+}
+
+func (s armRunSource) ListDatasets(
+	ctx context.Context,
+) ([]*armdatafactory.DatasetResource, error) {
+	pager := s.client.NewDatasetsClient().NewListByFactoryPager(
+		s.resourceGroupName,
+		s.factoryName,
+		nil,
+	)
+	return collectPages(ctx, pager, func(page armdatafactory.DatasetsClientListByFactoryResponse) []*armdatafactory.DatasetResource {
+		return page.Value
+	})
+}
+
+func (s armRunSource) ListLinkedServices(
+	ctx context.Context,
+) ([]*armdatafactory.LinkedServiceResource, error) {
+	pager := s.client.NewLinkedServicesClient().NewListByFactoryPager(
+		s.resourceGroupName,
+		s.factoryName,
+		nil,
+	)
+	return collectPages(ctx, pager, func(page armdatafactory.LinkedServicesClientListByFactoryResponse) []*armdatafactory.LinkedServiceResource {
+		return page.Value
+	})
+}
+
+func (s armRunSource) ListTriggers(
+	ctx context.Context,
+) ([]*armdatafactory.TriggerResource, error) {
+	pager := s.client.NewTriggersClient().NewListByFactoryPager(
+		s.resourceGroupName,
+		s.factoryName,
+		nil,
+	)
+	return collectPages(ctx, pager, func(page armdatafactory.TriggersClientListByFactoryResponse) []*armdatafactory.TriggerResource {
+		return page.Value
+	})
+}
+
+// collectPages reads every page of a list operation.
+func collectPages[P any, T any](
+	ctx context.Context,
+	pager *runtime.Pager[P],
+	value func(page P) []*T,
+) ([]*T, error) {
+	items := []*T{}
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value(page)...)
+	}
+	return items, nil
+}
+
+// fixtureRunSource reads a directory laid out as
+//
+//	factory.json           {"name": "...", "asOf": "<RFC3339>"}
+//	pipelines/*.json       pipeline definitions, as exported from ADF
+//	datasets/*.json        optional dataset definitions
+//	linkedServices/*.json  optional linked service definitions
+//	triggers/*.json        optional trigger definitions
+//	pipeline_runs.json     array of pipeline runs
+//	activity_runs.json     array of activity runs
+//
+// When asOf is set every run timestamp is shifted so that asOf becomes now,
+// which keeps "last n days" queries working against old fixtures.
+type fixtureRunSource struct {
+	dir string
+}
+
+type fixtureManifest struct {
+	Name string     `json:"name"`
+	AsOf *time.Time `json:"asOf"`
+}
+
+func readFixtureManifest(dir string) (fixtureManifest, error) {
+	manifest := fixtureManifest{Name: filepath.Base(dir)}
+	data, err := os.ReadFile(filepath.Join(dir, "factory.json"))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return manifest, err
+	}
+	err = json.Unmarshal(data, &manifest)
+	return manifest, err
+}
+
+func (s fixtureRunSource) timeShift() (time.Duration, error) {
+	manifest, err := readFixtureManifest(s.dir)
+	if err != nil || manifest.AsOf == nil {
+		return 0, err
+	}
+	return time.Since(*manifest.AsOf).Truncate(time.Second), nil
+}
+
+func (s fixtureRunSource) ListPipelines(
+	ctx context.Context,
+) ([]*armdatafactory.PipelineResource, error) {
+	return readFixtureResources[armdatafactory.PipelineResource](s.dir, "pipelines")
+}
+
+func (s fixtureRunSource) ListDatasets(
+	ctx context.Context,
+) ([]*armdatafactory.DatasetResource, error) {
+	return readFixtureResources[armdatafactory.DatasetResource](s.dir, "datasets")
+}
+
+func (s fixtureRunSource) ListLinkedServices(
+	ctx context.Context,
+) ([]*armdatafactory.LinkedServiceResource, error) {
+	return readFixtureResources[armdatafactory.LinkedServiceResource](s.dir, "linkedServices")
+}
+
+func (s fixtureRunSource) ListTriggers(
+	ctx context.Context,
+) ([]*armdatafactory.TriggerResource, error) {
+	return readFixtureResources[armdatafactory.TriggerResource](s.dir, "triggers")
+}
+
+// readFixtureResources reads every JSON file in a subdirectory of a fixture
+// directory, in file name order. A missing subdirectory has no resources.
+func readFixtureResources[T any](dir string, subdir string) ([]*T, error) {
+	files, err := filepath.Glob(filepath.Join(dir, subdir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(files)
+
+	resources := []*T{}
+	for _, file := range files {
+		resource := new(T)
+		if err := readJSONFile(file, resource); err != nil {
+			return nil, err
+		}
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
+
+func (s fixtureRunSource) GetPipeline(
+	ctx context.Context,
+	name string,
+) (armdatafactory.PipelineResource, error) {
+	pipelines, err := s.ListPipelines(ctx)
+	if err != nil {
+		return armdatafactory.PipelineResource{}, err
+	}
+	for _, pipeline := range pipelines {
+		if pipeline.Name != nil && *pipeline.Name == name {
+			return *pipeline, nil
+		}
+	}
+	return armdatafactory.PipelineResource{}, fmt.Errorf(
+		"pipeline %q not found in %s",
+		name,
+		s.dir,
+	)
+}
+
+func (s fixtureRunSource) loadPipelineRuns() ([]*armdatafactory.PipelineRun, error) {
+	runs := []*armdatafactory.PipelineRun{}
+	if err := readJSONFile(filepath.Join(s.dir, "pipeline_runs.json"), &runs); err != nil {
+		return nil, err
+	}
+
+	shift, err := s.timeShift()
+	if err != nil {
+		return nil, err
+	}
+	for _, run := range runs {
+		shiftTime(run.RunStart, shift)
+		shiftTime(run.RunEnd, shift)
+		shiftTime(run.LastUpdated, shift)
+	}
+	return runs, nil
+}
+
+func (s fixtureRunSource) loadActivityRuns() ([]*armdatafactory.ActivityRun, error) {
+	runs := []*armdatafactory.ActivityRun{}
+	if err := readJSONFile(filepath.Join(s.dir, "activity_runs.json"), &runs); err != nil {
+		return nil, err
+	}
+
+	shift, err := s.timeShift()
+	if err != nil {
+		return nil, err
+	}
+	for _, run := range runs {
+		shiftTime(run.ActivityRunStart, shift)
+		shiftTime(run.ActivityRunEnd, shift)
+	}
+	return runs, nil
+}
+
+func (s fixtureRunSource) GetPipelineRun(
+	ctx context.Context,
+	runID string,
+) (armdatafactory.PipelineRun, error) {
+	runs, err := s.loadPipelineRuns()
+	if err != nil {
+		return armdatafactory.PipelineRun{}, err
+	}
+	for _, run := range runs {
+		if stringValue(run.RunID) == runID {
+			return *run, nil
+		}
+	}
+	return armdatafactory.PipelineRun{}, fmt.Errorf(
+		"pipeline run %q not found in %s",
+		runID,
+		s.dir,
+	)
+}
+
+func (s fixtureRunSource) QueryPipelineRuns(
+	ctx context.Context,
+	filter armdatafactory.RunFilterParameters,
+) (armdatafactory.PipelineRunsQueryResponse, error) {
+	runs, err := s.loadPipelineRuns()
+	if err != nil {
+		return armdatafactory.PipelineRunsQueryResponse{}, err
+	}
+
+	matched := []*armdatafactory.PipelineRun{}
+	for _, run := range runs {
+		if !inRunWindow(run.LastUpdated, filter) {
+			continue
+		}
+		if !matchesRunFilters(filter.Filters, pipelineRunField(run)) {
+			continue
+		}
+		matched = append(matched, run)
+	}
+	orderPipelineRuns(matched, filter.OrderBy)
+	return armdatafactory.PipelineRunsQueryResponse{Value: matched}, nil
+}
+
+func (s fixtureRunSource) QueryActivityRuns(
+	ctx context.Context,
+	runID string,
+	filter armdatafactory.RunFilterParameters,
+) (armdatafactory.ActivityRunsQueryResponse, error) {
+	runs, err := s.loadActivityRuns()
+	if err != nil {
+		return armdatafactory.ActivityRunsQueryResponse{}, err
+	}
+
+	matched := []*armdatafactory.ActivityRun{}
+	for _, run := range runs {
+		if run.PipelineRunID == nil || *run.PipelineRunID != runID {
+			continue
+		}
+		if !matchesRunFilters(filter.Filters, activityRunField(run)) {
+			continue
+		}
+		matched = append(matched, run)
+	}
+	return armdatafactory.ActivityRunsQueryResponse{Value: matched}, nil
+}
+
+func (s fixtureRunSource) CreateRun(
+	ctx context.Context,
+	pipelineName string,
+	options *armdatafactory.PipelinesClientCreateRunOptions,
+) (string, error) {
+	return "", errFixturesReadOnly
+}
+
+func (s fixtureRunSource) CancelRun(
+	ctx context.Context,
+	runID string,
+	recursive bool,
+) error {
+	return errFixturesReadOnly
+}
+
+func readJSONFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func shiftTime(t *time.Time, shift time.Duration) {
+	if t != nil {
+		*t = t.Add(shift)
+	}
+}
+
+func inRunWindow(t *time.Time, filter armdatafactory.RunFilterParameters) bool {
+	if t == nil {
+		return true
+	}
+	if filter.LastUpdatedAfter != nil && t.Before(*filter.LastUpdatedAfter) {
+		return false
+	}
+	if filter.LastUpdatedBefore != nil && t.After(*filter.LastUpdatedBefore) {
+		return false
+	}
+	return true
+}
+
+func pipelineRunField(
+	run *armdatafactory.PipelineRun,
+) func(armdatafactory.RunQueryFilterOperand) (string, bool) {
+	return func(operand armdatafactory.RunQueryFilterOperand) (string, bool) {
+		switch operand {
+		case armdatafactory.RunQueryFilterOperandPipelineName:
+			return stringValue(run.PipelineName), true
+		case armdatafactory.RunQueryFilterOperandStatus:
+			return stringValue(run.Status), true
+		case armdatafactory.RunQueryFilterOperandRunGroupID:
+			return stringValue(run.RunGroupID), true
+		}
+		return "", false
+	}
+}
+
+func activityRunField(
+	run *armdatafactory.ActivityRun,
+) func(armdatafactory.RunQueryFilterOperand) (string, bool) {
+	return func(operand armdatafactory.RunQueryFilterOperand) (string, bool) {
+		switch operand {
+		case armdatafactory.RunQueryFilterOperandActivityName:
+			return stringValue(run.ActivityName), true
+		case armdatafactory.RunQueryFilterOperandActivityType:
+			return stringValue(run.ActivityType), true
+		case armdatafactory.RunQueryFilterOperandStatus:
+			return stringValue(run.Status), true
+		}
+		return "", false
+	}
+}
+
+// matchesRunFilters applies the subset of ADF run query filters that can be
+// evaluated against string fields. Unsupported operands are ignored.
+func matchesRunFilters(
+	filters []*armdatafactory.RunQueryFilter,
+	field func(armdatafactory.RunQueryFilterOperand) (string, bool),
+) bool {
+	for _, filter := range filters {
+		if filter == nil || filter.Operand == nil || filter.Operator == nil {
+			continue
+		}
+		value, ok := field(*filter.Operand)
+		if !ok {
+			continue
+		}
+
+		values := make([]string, 0, len(filter.Values))
+		for _, v := range filter.Values {
+			values = append(values, stringValue(v))
+		}
+
+		found := slices.Contains(values, value)
+		switch *filter.Operator {
+		case armdatafactory.RunQueryFilterOperatorEquals,
+			armdatafactory.RunQueryFilterOperatorIn:
+			if !found {
+				return false
+			}
+		case armdatafactory.RunQueryFilterOperatorNotEquals,
+			armdatafactory.RunQueryFilterOperatorNotIn:
+			if found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// orderPipelineRuns sorts runs by the fields of an ADF run query's orderBy,
+// keeping their order where it sets none.
+func orderPipelineRuns(
+	runs []*armdatafactory.PipelineRun,
+	orderBy []*armdatafactory.RunQueryOrderBy,
+) {
+	slices.SortStableFunc(runs, func(a, b *armdatafactory.PipelineRun) int {
+		for _, order := range orderBy {
+			if order == nil || order.OrderBy == nil {
+				continue
+			}
+			result := 0
+			switch *order.OrderBy {
+			case armdatafactory.RunQueryOrderByFieldRunStart:
+				result = compareTimes(a.RunStart, b.RunStart)
+			case armdatafactory.RunQueryOrderByFieldRunEnd:
+				result = compareTimes(a.RunEnd, b.RunEnd)
+			case armdatafactory.RunQueryOrderByFieldPipelineName:
+				result = strings.Compare(stringValue(a.PipelineName), stringValue(b.PipelineName))
+			case armdatafactory.RunQueryOrderByFieldStatus:
+				result = strings.Compare(stringValue(a.Status), stringValue(b.Status))
+			}
+			if order.Order != nil && *order.Order == armdatafactory.RunQueryOrderDESC {
+				result = -result
+			}
+			if result != 0 {
+				return result
+			}
+		}
+		return 0
+	})
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func timeValue(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+func int32Value(i *int32) int32 {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
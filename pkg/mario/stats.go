@@ -0,0 +1,179 @@
+package mario
+
+import (
+	"math"
+	"slices"
+)
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// medianAbsoluteDeviation is the median distance of values from their
+// median. Unlike the standard deviation, a few extreme values barely move it.
+func medianAbsoluteDeviation(values []float64) float64 {
+	center := median(values)
+	deviations := make([]float64, len(values))
+	for i, value := range values {
+		deviations[i] = math.Abs(value - center)
+	}
+	return median(deviations)
+}
+
+// minBaselineRuns is the fewest runs a baseline needs before runs are scored
+// against it.
+const minBaselineRuns = 5
+
+// defaultAnomalyThreshold is the robust z-score above which a run is
+// flagged, the cutoff suggested by Iglewicz and Hoaglin.
+const defaultAnomalyThreshold = 3.5
+
+// durationBaseline is the typical duration of a pipeline over a window.
+type durationBaseline struct {
+	median float64
+	mad    float64
+	runs   int
+}
+
+func newDurationBaseline(durations []float64) durationBaseline {
+	return durationBaseline{
+		median: median(durations),
+		mad:    medianAbsoluteDeviation(durations),
+		runs:   len(durations),
+	}
+}
+
+// zScore is the robust z-score of a duration: its distance from the median
+// in units of the MAD scaled to match a standard deviation. It is 0 when the
+// baseline has too few runs or no spread.
+func (baseline durationBaseline) zScore(duration float64) float64 {
+	if baseline.runs < minBaselineRuns || baseline.mad == 0 {
+		return 0
+	}
+	return 0.6745 * (duration - baseline.median) / baseline.mad
+}
+
+// pctFromMedian is how much longer, or shorter when negative, a duration is
+// than the median.
+func (baseline durationBaseline) pctFromMedian(duration float64) float64 {
+	if baseline.median == 0 {
+		return 0
+	}
+	return (duration - baseline.median) / baseline.median * 100
+}
+
+// minShiftScore is how far apart, in pooled robust z-scores, the medians on
+// either side of a change point must be.
+const minShiftScore = 3.0
+
+// changePoints finds the indexes where a series shifts to a new level by
+// binary segmentation: each segment is split where the summed absolute
+// deviation from the median of each side drops the most, as long as both
+// sides keep minSegment values and their medians differ by minShiftScore
+// robust z-scores and by minChangePct percent. The indexes are the first
+// value of each new level, in order.
+func changePoints(values []float64, minSegment int, minChangePct float64) []int {
+	minSegment = max(minSegment, 2)
+	points := []int{}
+
+	var split func(start int, end int)
+	split = func(start int, end int) {
+		if end-start < 2*minSegment {
+			return
+		}
+
+		segment := values[start:end]
+		total := absoluteDeviation(segment)
+		best, bestGain := -1, 0.0
+		for i := minSegment; i <= len(segment)-minSegment; i++ {
+			gain := total - absoluteDeviation(segment[:i]) - absoluteDeviation(segment[i:])
+			if gain > bestGain {
+				best, bestGain = i, gain
+			}
+		}
+		if best < 0 || !isShift(segment[:best], segment[best:], minChangePct) {
+			return
+		}
+
+		split(start, start+best)
+		points = append(points, start+best)
+		split(start+best, end)
+	}
+	split(0, len(values))
+	return points
+}
+
+// absoluteDeviation is the summed distance of values from their median.
+func absoluteDeviation(values []float64) float64 {
+	center := median(values)
+	total := 0.0
+	for _, value := range values {
+		total += math.Abs(value - center)
+	}
+	return total
+}
+
+func isShift(before []float64, after []float64, minChangePct float64) bool {
+	medianBefore := median(before)
+	shift := math.Abs(median(after) - medianBefore)
+	if medianBefore == 0 || shift/medianBefore*100 < minChangePct {
+		return false
+	}
+
+	spread := (medianAbsoluteDeviation(before)*float64(len(before)) +
+		medianAbsoluteDeviation(after)*float64(len(after))) /
+		float64(len(before)+len(after))
+	return spread == 0 || 0.6745*shift/spread >= minShiftScore
+}
+
+// percentile interpolates between the closest ranks of values, so the 50th
+// percentile is the median. p is between 0 and 100.
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+	return total / float64(len(values))
+}
+
+// standardDeviation is the sample standard deviation, 0 for fewer than two
+// values.
+func standardDeviation(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	center := mean(values)
+	sumSquares := 0.0
+	for _, value := range values {
+		sumSquares += (value - center) * (value - center)
+	}
+	return math.Sqrt(sumSquares / float64(len(values)-1))
+}
@@ -0,0 +1,127 @@
+package mario
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// series concatenates levels of n values each, alternating between the level
+// and the level plus noise.
+func series(n int, noise float64, levels ...float64) []float64 {
+	values := []float64{}
+	for _, level := range levels {
+		for i := 0; i < n; i++ {
+			values = append(values, level+float64(i%2)*noise)
+		}
+	}
+	return values
+}
+
+func TestChangePoints(t *testing.T) {
+	tests := []struct {
+		name         string
+		values       []float64
+		minSegment   int
+		minChangePct float64
+		want         []int
+	}{
+		{
+			name:         "flat",
+			values:       series(20, 2, 100),
+			minSegment:   5,
+			minChangePct: 20,
+			want:         []int{},
+		},
+		{
+			name:         "one shift up",
+			values:       series(10, 2, 100, 200),
+			minSegment:   5,
+			minChangePct: 20,
+			want:         []int{10},
+		},
+		{
+			name:         "shift down",
+			values:       series(10, 2, 300, 100),
+			minSegment:   5,
+			minChangePct: 20,
+			want:         []int{10},
+		},
+		{
+			name:         "two shifts",
+			values:       series(10, 2, 100, 200, 400),
+			minSegment:   5,
+			minChangePct: 20,
+			want:         []int{10, 20},
+		},
+		{
+			name:         "change below min-change",
+			values:       series(10, 0, 100, 110),
+			minSegment:   5,
+			minChangePct: 20,
+			want:         []int{},
+		},
+		{
+			name:         "shift within the noise",
+			values:       series(10, 60, 100, 130),
+			minSegment:   5,
+			minChangePct: 20,
+			want:         []int{},
+		},
+		{
+			name:         "new level too short",
+			values:       series(10, 2, 100, 200)[:12],
+			minSegment:   5,
+			minChangePct: 20,
+			want:         []int{},
+		},
+		{
+			name:         "single outlier",
+			values:       append(series(10, 2, 100), append([]float64{900}, series(10, 2, 100)...)...),
+			minSegment:   3,
+			minChangePct: 20,
+			want:         []int{},
+		},
+		{
+			name:         "too few values",
+			values:       []float64{100, 200, 300},
+			minSegment:   1,
+			minChangePct: 20,
+			want:         []int{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := changePoints(test.values, test.minSegment, test.minChangePct)
+			if !slices.Equal(got, test.want) {
+				t.Errorf("changePoints() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		p      float64
+		want   float64
+	}{
+		{name: "empty", values: []float64{}, p: 50, want: 0},
+		{name: "one value", values: []float64{7}, p: 95, want: 7},
+		{name: "median of an odd count", values: []float64{3, 1, 2}, p: 50, want: 2},
+		{name: "median of an even count", values: []float64{4, 1, 3, 2}, p: 50, want: 2.5},
+		{name: "interpolated", values: []float64{10, 20, 30, 40, 50}, p: 90, want: 46},
+		{name: "min", values: []float64{10, 20, 30}, p: 0, want: 10},
+		{name: "max", values: []float64{10, 20, 30}, p: 100, want: 30},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := percentile(test.values, test.p); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("percentile(%v, %v) = %v, want %v", test.values, test.p, got, test.want)
+			}
+		})
+	}
+}
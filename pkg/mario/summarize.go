@@ -1,12 +1,12 @@
 package mario
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"log"
 	"slices"
 	"strings"
-	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/datafactory/armdatafactory/v3"
 	"github.com/fatih/color"
@@ -14,25 +14,68 @@ import (
 )
 
 type FactoryPipelineSummary struct {
-	factoryName           string
-	folder                string
-	nPipelines            int
-	nActivities           int
-	nCopyActivities       int
-	nDatabricksActivities int
+	FactoryName          string `json:"factoryName" yaml:"factoryName"`
+	Profile              string `json:"profile,omitempty" yaml:"profile,omitempty"`
+	Folder               string `json:"folder" yaml:"folder"`
+	Pipelines            int    `json:"pipelines" yaml:"pipelines"`
+	Activities           int    `json:"activities" yaml:"activities"`
+	CopyActivities       int    `json:"copyActivities" yaml:"copyActivities"`
+	DatabricksActivities int    `json:"databricksActivities" yaml:"databricksActivities"`
+
+	// index of the factory among those read
+	factory int
 }
 
 func SummarizePipelines() {
 	defer timer("SummarizePipelines")()
-	factory := getFactoryClient()
+	factories := getFactoryClients()
 	ctx := context.Background()
 
-	pipelines := getAllPipelines(&factory, ctx)
-	pipelineDetailsSummary := summarizePipelineDetails(factory, pipelines)
-	printPipelineDetailsSummary(pipelineDetailsSummary)
+	type factorySummary struct {
+		summaries []FactoryPipelineSummary
+		err       error
+	}
+	factorySummaries := queryFactories(
+		factories,
+		func(factory *Factory) factorySummary {
+			pipelines, err := fetchAllPipelines(factory, ctx)
+			return factorySummary{summarizePipelineDetails(*factory, pipelines), err}
+		},
+	)
+
+	errs := []error{}
+	pipelineDetailsSummary := []FactoryPipelineSummary{}
+	for i, factorySummary := range factorySummaries {
+		errs = append(errs, factorySummary.err)
+		if factorySummary.err != nil {
+			continue
+		}
+		for _, summary := range factorySummary.summaries {
+			summary.factory = i
+			pipelineDetailsSummary = append(pipelineDetailsSummary, summary)
+		}
+	}
+	skipFailedFactories(factories, errs)
+	slices.SortFunc(pipelineDetailsSummary, func(a, b FactoryPipelineSummary) int {
+		if a.factory != b.factory {
+			return cmp.Compare(a.factory, b.factory)
+		}
+		return strings.Compare(a.Folder, b.Folder)
+	})
+
+	if !tableOutput() {
+		if err := writeRecords(pipelineDetailsSummary); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	printPipelineDetailsSummary(factories, pipelineDetailsSummary)
 }
 
-func printPipelineDetailsSummary(pipelineSummary []FactoryPipelineSummary) {
+func printPipelineDetailsSummary(
+	factories []Factory,
+	pipelineSummary []FactoryPipelineSummary,
+) {
 
 	defer timer("printPipelineRunSummary")()
 	headerLength := 80
@@ -67,15 +110,35 @@ func printPipelineDetailsSummary(pipelineSummary []FactoryPipelineSummary) {
 
 	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
 
-	for _, summary := range pipelineSummary {
+	subtotal := FactoryPipelineSummary{}
+	for i, summary := range pipelineSummary {
 		tbl.AddRow(
-			summary.factoryName,
-			summary.folder,
-			summary.nPipelines,
-			summary.nActivities,
-			summary.nCopyActivities,
-			summary.nDatabricksActivities,
+			factories[summary.factory].label(),
+			summary.Folder,
+			summary.Pipelines,
+			summary.Activities,
+			summary.CopyActivities,
+			summary.DatabricksActivities,
 		)
+
+		subtotal.Pipelines += summary.Pipelines
+		subtotal.Activities += summary.Activities
+		subtotal.CopyActivities += summary.CopyActivities
+		subtotal.DatabricksActivities += summary.DatabricksActivities
+
+		lastOfFactory := i == len(pipelineSummary)-1 ||
+			pipelineSummary[i+1].factory != summary.factory
+		if len(factories) > 1 && lastOfFactory {
+			tbl.AddRow(
+				"",
+				subtotalFormat("subtotal"),
+				subtotalFormat(subtotal.Pipelines),
+				subtotalFormat(subtotal.Activities),
+				subtotalFormat(subtotal.CopyActivities),
+				subtotalFormat(subtotal.DatabricksActivities),
+			)
+			subtotal = FactoryPipelineSummary{}
+		}
 	}
 
 	tbl.Print()
@@ -94,13 +157,7 @@ func summarizePipelineDetails(
 	pipelinesByFolder := make(map[string][]armdatafactory.PipelineResource)
 
 	for _, pipeline := range pipelines {
-		pipelineFolder := ""
-		pipelineFolderPointer := pipeline.Properties.Folder
-		if pipelineFolderPointer == nil {
-			pipelineFolder = "root"
-		} else {
-			pipelineFolder = *pipelineFolderPointer.Name
-		}
+		pipelineFolder := getPipelineFolder(pipeline)
 
 		if !slices.Contains(uniqueFolders, pipelineFolder) {
 			// add folder to map for the first time
@@ -141,42 +198,169 @@ func summarizePipelineDetails(
 		}
 
 		pipelineSummary = append(pipelineSummary, FactoryPipelineSummary{
-			factoryName:           factory.factoryName,
-			folder:                folder,
-			nPipelines:            nPipelines,
-			nActivities:           nActivities,
-			nCopyActivities:       nCopyActivities,
-			nDatabricksActivities: nDatabricksActivities,
+			FactoryName:          factory.factoryName,
+			Profile:              factory.profile,
+			Folder:               folder,
+			Pipelines:            nPipelines,
+			Activities:           nActivities,
+			CopyActivities:       nCopyActivities,
+			DatabricksActivities: nDatabricksActivities,
 		})
 	}
 	return pipelineSummary
 }
 
-func SummarizeRuns(nDays int, name string) {
+func getPipelineFolder(pipeline *armdatafactory.PipelineResource) string {
+	if pipeline.Properties == nil || pipeline.Properties.Folder == nil {
+		return "root"
+	}
+	return *pipeline.Properties.Folder.Name
+}
+
+// summaryStat is a column summarize runs can show with --stats.
+type summaryStat struct {
+	name   string
+	header string
+	value  func(summary PipelineRunSummary) string
+}
+
+// summaryStats are the columns --stats picks from, in the order they are
+// shown.
+var summaryStats = []summaryStat{
+	{"avg", "Avg Time (min)", minutesStat(func(s PipelineRunSummary) float32 { return s.RuntimeAvgMin })},
+	{"total", "Total Time (min)", minutesStat(func(s PipelineRunSummary) float32 { return s.RuntimeTotalMin })},
+	{"p50", "P50 (min)", minutesStat(func(s PipelineRunSummary) float32 { return s.RuntimeP50Min })},
+	{"p90", "P90 (min)", minutesStat(func(s PipelineRunSummary) float32 { return s.RuntimeP90Min })},
+	{"p95", "P95 (min)", minutesStat(func(s PipelineRunSummary) float32 { return s.RuntimeP95Min })},
+	{"max", "Max (min)", minutesStat(func(s PipelineRunSummary) float32 { return s.RuntimeMaxMin })},
+	{"stddev", "Std Dev (min)", minutesStat(func(s PipelineRunSummary) float32 { return s.RuntimeStdDevMin })},
+	{"success-rate", "Success %", func(s PipelineRunSummary) string {
+		if s.Success+s.Failed+s.Cancelled == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%.1f", s.SuccessRate)
+	}},
+	{"last", "Last Status", func(s PipelineRunSummary) string { return s.LastStatus }},
+}
+
+func minutesStat(value func(summary PipelineRunSummary) float32) func(PipelineRunSummary) string {
+	return func(summary PipelineRunSummary) string {
+		return fmt.Sprintf("%.2f", value(summary))
+	}
+}
+
+// selectSummaryStats returns the columns named by --stats.
+func selectSummaryStats(names []string) ([]summaryStat, error) {
+	known := []string{}
+	for _, stat := range summaryStats {
+		known = append(known, stat.name)
+	}
+
+	selected := []summaryStat{}
+	for _, stat := range summaryStats {
+		if slices.Contains(names, stat.name) {
+			selected = append(selected, stat)
+		}
+	}
+	for _, name := range names {
+		if !slices.Contains(known, name) {
+			return nil, fmt.Errorf("stat %q is not one of %s", name, strings.Join(known, ", "))
+		}
+	}
+	return selected, nil
+}
+
+// SummarizeRuns prints the run counts of every pipeline matching name and
+// the statistics in stats.
+func SummarizeRuns(query RunQuery, name string, stats []string) {
 	defer timer("SummarizeRuns")()
-	factory := getFactoryClient()
+	columns, err := selectSummaryStats(stats)
+	if err != nil {
+		log.Fatal(err)
+	}
+	factories := getFactoryClients()
 	ctx := context.Background()
 
-	pipelineRuns, _ := getPipelineRuns(&factory, ctx, nDays, "")
-	pipelineSummary := summarizePipelineRuns(pipelineRuns)
+	type factorySummary struct {
+		summaries map[string]PipelineRunSummary
+		err       error
+	}
+	factorySummaries := queryFactories(
+		factories,
+		func(factory *Factory) factorySummary {
+			pipelineRuns, err := fetchPipelineRuns(factory, ctx, query, "")
+			return factorySummary{summarizePipelineRuns(pipelineRuns), err}
+		},
+	)
 
-	if name == "" {
-		printPipelineRunSummary(pipelineSummary)
-		return
+	errs := []error{}
+	pipelineSummary := []PipelineRunSummary{}
+	for i, factorySummary := range factorySummaries {
+		errs = append(errs, factorySummary.err)
+		if factorySummary.err != nil {
+			continue
+		}
+		for _, summary := range factorySummary.summaries {
+			// if name is not empty, filter the pipeline summary
+			if !strings.Contains(summary.PipelineName, name) {
+				continue
+			}
+			summary.FactoryName = factories[i].factoryName
+			summary.Profile = factories[i].profile
+			summary.factory = i
+			summary = summary.withStats()
+			pipelineSummary = append(pipelineSummary, summary)
+		}
 	}
+	skipFailedFactories(factories, errs)
 
-	// if name is not empty, filter the pipeline summary
-	filteredPipelineSummary := make(map[string]PipelineRunSummary)
-	for _, summary := range pipelineSummary {
-		if strings.Contains(summary.PipelineName, name) {
-			filteredPipelineSummary[summary.PipelineName] = summary
+	slices.SortFunc(pipelineSummary, func(a, b PipelineRunSummary) int {
+		if a.factory != b.factory {
+			return cmp.Compare(a.factory, b.factory)
 		}
+		return strings.Compare(a.PipelineName, b.PipelineName)
+	})
+
+	if !tableOutput() {
+		if err := writeRecords(pipelineSummary); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	printPipelineRunSummary(filteredPipelineSummary)
+	printPipelineRunSummary(factories, pipelineSummary, columns)
+}
+
+// withStats fills in the runtime statistics and success rate from the
+// durations and counts collected by summarizePipelineRuns. The total covers
+// every finished run; the other runtime statistics cover succeeded runs only,
+// since failed and cancelled runs often stop early.
+func (summary PipelineRunSummary) withStats() PipelineRunSummary {
+	total := 0.0
+	for _, duration := range summary.durations {
+		total += duration
+	}
+	summary.RuntimeTotalMin = float32(total)
+
+	durations := summary.succeededDurations
+	summary.RuntimeAvgMin = float32(mean(durations))
+	summary.RuntimeP50Min = float32(percentile(durations, 50))
+	summary.RuntimeP90Min = float32(percentile(durations, 90))
+	summary.RuntimeP95Min = float32(percentile(durations, 95))
+	summary.RuntimeStdDevMin = float32(standardDeviation(durations))
+	summary.RuntimeMaxMin = 0
+	if len(durations) > 0 {
+		summary.RuntimeMaxMin = float32(slices.Max(durations))
+	}
+
+	summary.SuccessRate = 0
+	if finished := summary.Success + summary.Failed + summary.Cancelled; finished > 0 {
+		summary.SuccessRate = float32(summary.Success) / float32(finished) * 100
+	}
+	return summary
 }
 
 func summarizePipelineRuns(
-	runs armdatafactory.PipelineRunsClientQueryByFactoryResponse,
+	runs armdatafactory.PipelineRunsQueryResponse,
 ) map[string]PipelineRunSummary {
 	defer timer("summarizePipelineRuns")()
 	pipelineRunSummary := make(map[string]PipelineRunSummary)
@@ -188,24 +372,50 @@ func summarizePipelineRuns(
 			}
 		}
 
+		finished := false
 		switch *run.Status {
 		case "Succeeded":
 			summary.Success++
+			finished = true
 		case "Failed":
 			summary.Failed++
+			finished = true
+		case "Cancelled":
+			summary.Cancelled++
+			finished = true
 		case "InProgress":
 			summary.InProgress++
 		}
 
-		summary.RuntimeTotalMin += float32(*run.DurationInMs) / (1000 * 60)
+		// in progress runs report the time so far, and runs cancelled before
+		// starting have no duration
+		if finished && run.DurationInMs != nil {
+			duration := float64(*run.DurationInMs) / (1000 * 60)
+			summary.durations = append(summary.durations, duration)
+			if *run.Status == "Succeeded" {
+				summary.succeededDurations = append(summary.succeededDurations, duration)
+			}
+		}
+
+		if run.RunStart != nil && run.RunStart.After(summary.LastRunStart) {
+			summary.LastRunStart = *run.RunStart
+			summary.LastStatus = *run.Status
+		}
 		pipelineRunSummary[*run.PipelineName] = summary
 	}
 
 	return pipelineRunSummary
 }
 
-func printPipelineRunSummary(pipelineRunSummary map[string]PipelineRunSummary) {
+// printPipelineRunSummary prints one row per pipeline. When several factories
+// were read a Factory column and a subtotal per factory are added.
+func printPipelineRunSummary(
+	factories []Factory,
+	pipelineRunSummary []PipelineRunSummary,
+	stats []summaryStat,
+) {
 	defer timer("printPipelineRunSummary")()
+	byFactory := len(factories) > 1
 
 	headerLength := 80
 
@@ -235,29 +445,55 @@ func printPipelineRunSummary(pipelineRunSummary map[string]PipelineRunSummary) {
 	headerFmt := color.New(color.Underline).SprintfFunc()
 	columnFmt := color.New(color.FgYellow).SprintfFunc()
 
-	tbl := table.New(
-		"Pipeline",
-		"Avg Time (min)",
-		"Total Time (min)",
-		"\u2714",
-		"\u2718",
-		"\u2022\u2022\u2022",
-	)
+	columns := []interface{}{"Pipeline"}
+	for _, stat := range stats {
+		columns = append(columns, stat.header)
+	}
+	columns = append(columns, "\u2714", "\u2718", "\u2022\u2022\u2022")
+	if byFactory {
+		columns = append([]interface{}{"Factory"}, columns...)
+	}
+	tbl := table.New(columns...)
 
 	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
 
-	for _, summary := range pipelineRunSummary {
-		summary.RuntimeAvgMin = summary.RuntimeTotalMin / float32(
-			summary.Success+summary.Failed,
-		)
-		tbl.AddRow(
-			summary.PipelineName,
-			summary.RuntimeAvgMin,
-			summary.RuntimeTotalMin,
-			summary.Success,
-			summary.Failed,
-			summary.InProgress,
-		)
+	subtotal := PipelineRunSummary{PipelineName: "subtotal"}
+	for i, summary := range pipelineRunSummary {
+		row := []interface{}{summary.PipelineName}
+		for _, stat := range stats {
+			row = append(row, stat.value(summary))
+		}
+		row = append(row, summary.Success, summary.Failed, summary.InProgress)
+		if !byFactory {
+			tbl.AddRow(row...)
+			continue
+		}
+		tbl.AddRow(append([]interface{}{factories[summary.factory].label()}, row...)...)
+
+		subtotal.Success += summary.Success
+		subtotal.Failed += summary.Failed
+		subtotal.Cancelled += summary.Cancelled
+		subtotal.InProgress += summary.InProgress
+		subtotal.durations = append(subtotal.durations, summary.durations...)
+		subtotal.succeededDurations = append(subtotal.succeededDurations, summary.succeededDurations...)
+
+		lastOfFactory := i == len(pipelineRunSummary)-1 ||
+			pipelineRunSummary[i+1].factory != summary.factory
+		if lastOfFactory {
+			subtotal = subtotal.withStats()
+			subtotalRow := []interface{}{"", subtotalFormat(subtotal.PipelineName)}
+			for _, stat := range stats {
+				subtotalRow = append(subtotalRow, subtotalFormat(stat.value(subtotal)))
+			}
+			subtotalRow = append(
+				subtotalRow,
+				subtotalFormat(subtotal.Success),
+				subtotalFormat(subtotal.Failed),
+				subtotalFormat(subtotal.InProgress),
+			)
+			tbl.AddRow(subtotalRow...)
+			subtotal = PipelineRunSummary{PipelineName: "subtotal"}
+		}
 	}
 
 	tbl.Print()
@@ -265,103 +501,18 @@ func printPipelineRunSummary(pipelineRunSummary map[string]PipelineRunSummary) {
 	fmt.Println(footer)
 }
 
-func getAllPipelines(
+func fetchAllPipelines(
 	factory *Factory,
 	ctx context.Context,
-) []*armdatafactory.PipelineResource {
+) ([]*armdatafactory.PipelineResource, error) {
 	// list all pipelines in the factory
-	defer timer("getAllPipelines")()
-	pipelineClient := factory.factoryClient.NewPipelinesClient()
-	pager := pipelineClient.NewListByFactoryPager(
-		factory.resouceGroupName,
-		factory.factoryName,
-		nil,
-	)
-
-	pipelines := []armdatafactory.PipelineResource{}
-
-	for pager.More() {
-		page, err := pager.NextPage(ctx)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		for _, value := range page.Value {
-			pipelines = append(pipelines, *value)
-		}
-	}
-
-	fmt.Println("obtained", len(pipelines), "pipelines")
-
-	var pipelinePointers []*armdatafactory.PipelineResource
-	for _, pipeline := range pipelines {
-		pipelinePointers = append(pipelinePointers, &pipeline)
-	}
-
-	return pipelinePointers
-}
-
-func getPipelineRuns(
-	factory *Factory,
-	ctx context.Context,
-	nDays int,
-	name string,
-) (armdatafactory.PipelineRunsClientQueryByFactoryResponse, error) {
-	defer timer("getPipelineRuns")()
-	if nDays < 1 {
-		log.Fatalf("nDays must be greater than 0")
-	}
-
-	if nDays > 30 {
-		log.Fatalf("nDays must be less than 30")
-	}
-
-	runsFrom := time.Now().AddDate(0, 0, -nDays)
-	runsTo := time.Now().
-		AddDate(0, 0, 1)
-		// add 1 day to include today and handle timezones
-
-	runFilterParameters := armdatafactory.RunFilterParameters{
-		LastUpdatedAfter:  &runsFrom,
-		LastUpdatedBefore: &runsTo,
-	}
-
-	if name != "" {
-		operand := armdatafactory.RunQueryFilterOperandPipelineName
-		operator := armdatafactory.RunQueryFilterOperatorEquals
-		nameFilter := make([]*string, 1)
-		nameFilter[0] = &name
-		filter := armdatafactory.RunQueryFilter{
-			Operand:  &operand,
-			Operator: &operator,
-			Values:   nameFilter,
-		}
-
-		runFilterParameters = armdatafactory.RunFilterParameters{
-			LastUpdatedAfter:  &runsFrom,
-			LastUpdatedBefore: &runsTo,
-			Filters:           []*armdatafactory.RunQueryFilter{&filter},
-		}
-	}
-
-	log.Printf(
-		"Getting pipeline runs from %s to %s",
-		runsFrom.Format("2006-01-02"),
-		runsTo.Format("2006-01-02"),
-	)
-
-	pipelineRunsClient := factory.factoryClient.NewPipelineRunsClient()
-	pipelineRuns, err := pipelineRunsClient.QueryByFactory(
-		ctx,
-		factory.resouceGroupName,
-		factory.factoryName,
-		runFilterParameters,
-		nil,
-	)
-
+	defer timer("fetchAllPipelines")()
+	pipelines, err := factory.source.ListPipelines(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	return pipelineRuns, nil
+	progressLogger.Println("obtained", len(pipelines), "pipelines")
+
+	return pipelines, nil
 }
@@ -0,0 +1,113 @@
+package mario
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/datafactory/armdatafactory/v3"
+)
+
+// minuteRuns returns a run of pipeline for each status, lasting the matching
+// number of minutes and started an hour apart.
+func minuteRuns(pipeline string, statuses []string, minutes []int32) []*armdatafactory.PipelineRun {
+	midnight := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	runs := []*armdatafactory.PipelineRun{}
+	for i, status := range statuses {
+		runs = append(runs, &armdatafactory.PipelineRun{
+			PipelineName: to(pipeline),
+			Status:       to(status),
+			RunStart:     to(midnight.Add(time.Duration(i) * time.Hour)),
+			DurationInMs: to(minutes[i] * 60 * 1000),
+		})
+	}
+	return runs
+}
+
+func TestSummarizeRunsWithStats(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		minutes  []int32
+		want     PipelineRunSummary
+	}{
+		{
+			name:     "succeeded runs",
+			statuses: []string{"Succeeded", "Succeeded", "Succeeded", "Succeeded", "Succeeded"},
+			minutes:  []int32{10, 20, 30, 40, 50},
+			want: PipelineRunSummary{
+				Success:          5,
+				RuntimeTotalMin:  150,
+				RuntimeAvgMin:    30,
+				RuntimeP50Min:    30,
+				RuntimeP90Min:    46,
+				RuntimeP95Min:    48,
+				RuntimeMaxMin:    50,
+				RuntimeStdDevMin: 15.811388,
+				SuccessRate:      100,
+				LastStatus:       "Succeeded",
+			},
+		},
+		{
+			name:     "runs that stopped early only count towards the total",
+			statuses: []string{"Succeeded", "Failed", "Succeeded", "Cancelled", "Succeeded", "Succeeded", "Succeeded", "Succeeded"},
+			minutes:  []int32{30, 1, 30, 2, 30, 30, 30, 30},
+			want: PipelineRunSummary{
+				Success:         6,
+				Failed:          1,
+				Cancelled:       1,
+				RuntimeTotalMin: 183,
+				RuntimeAvgMin:   30,
+				RuntimeP50Min:   30,
+				RuntimeP90Min:   30,
+				RuntimeP95Min:   30,
+				RuntimeMaxMin:   30,
+				SuccessRate:     75,
+				LastStatus:      "Succeeded",
+			},
+		},
+		{
+			name:     "in progress runs are not finished",
+			statuses: []string{"Succeeded", "InProgress"},
+			minutes:  []int32{10, 500},
+			want: PipelineRunSummary{
+				Success:         1,
+				InProgress:      1,
+				RuntimeTotalMin: 10,
+				RuntimeAvgMin:   10,
+				RuntimeP50Min:   10,
+				RuntimeP90Min:   10,
+				RuntimeP95Min:   10,
+				RuntimeMaxMin:   10,
+				SuccessRate:     100,
+				LastStatus:      "InProgress",
+			},
+		},
+		{
+			name:     "no succeeded runs",
+			statuses: []string{"Failed", "Failed"},
+			minutes:  []int32{5, 7},
+			want: PipelineRunSummary{
+				Failed:          2,
+				RuntimeTotalMin: 12,
+				LastStatus:      "Failed",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runs := minuteRuns("mario_job", test.statuses, test.minutes)
+			summaries := summarizePipelineRuns(armdatafactory.PipelineRunsQueryResponse{Value: runs})
+			got := summaries["mario_job"].withStats()
+
+			want := test.want
+			want.PipelineName = "mario_job"
+			want.LastRunStart = *runs[len(runs)-1].RunStart
+			got.durations, got.succeededDurations = nil, nil
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("withStats() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
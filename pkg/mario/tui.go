@@ -0,0 +1,682 @@
+package mario
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/datafactory/armdatafactory/v3"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const tuiHelp = "[yellow]tab[-] pane  [yellow]j/k[-] move  [yellow]/[-] filter  [yellow]m[-] mark for diff  [yellow]d[-] definition/diff  [yellow]r[-] refresh  [yellow]q[-] quit"
+
+// tuiSelection is what the pipeline tree points at: the whole factory, a
+// folder, or a single pipeline.
+type tuiSelection struct {
+	folder   string
+	pipeline string
+}
+
+type tui struct {
+	app     *tview.Application
+	factory Factory
+	query   RunQuery
+	refresh time.Duration
+
+	pipelinesView  *tview.TreeView
+	runsView       *tview.Table
+	activitiesView *tview.Table
+	definitionView *tview.TextView
+	statusBar      *tview.TextView
+	filterInput    *tview.InputField
+	bottom         *tview.Pages
+	panes          []*tview.Box
+	focusables     []tview.Primitive
+
+	// data, only touched on the UI goroutine once the app is running
+	pipelines     []*armdatafactory.PipelineResource
+	folders       []FactoryPipelineSummary
+	runSummary    map[string]PipelineRunSummary
+	runs          []*armdatafactory.PipelineRun
+	activityTrees map[string][]*activityNode
+	lastRefresh   time.Time
+	// the last failed reload, shown until one succeeds
+	refreshErr error
+
+	// the run whose activities are loading and how to abandon the load
+	// when another run is selected
+	loadingRun     string
+	cancelActivity context.CancelFunc
+
+	filter      string
+	selection   tuiSelection
+	selectedRun *armdatafactory.PipelineRun
+	marked      string
+	showDiff    bool
+}
+
+// RunTUI starts a full-screen interface with panes for the pipeline tree,
+// recent runs, the activity runs of the selected run and the pipeline
+// definition or a diff against a marked pipeline.
+func RunTUI(query RunQuery, refresh time.Duration) {
+	factory := getFactoryClient()
+	ctx := context.Background()
+
+	t := &tui{
+		app:           tview.NewApplication(),
+		factory:       factory,
+		query:         query,
+		refresh:       refresh,
+		activityTrees: map[string][]*activityNode{},
+	}
+
+	pipelines, folders, err := t.loadPipelines(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	runs, runSummary, err := t.loadRuns(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	t.pipelines, t.folders = pipelines, folders
+	t.runs, t.runSummary = runs, runSummary
+	t.lastRefresh = time.Now()
+
+	// timings and progress would draw over the screen, and a fatal error
+	// has to restore the terminal before the message is printed
+	defer silenceOutput(t.app)()
+
+	t.build()
+	t.renderPipelines()
+	t.renderRuns()
+	t.renderDefinition()
+	t.renderStatus()
+
+	if refresh > 0 {
+		go t.autoRefresh()
+	}
+
+	if err := t.app.Run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func silenceOutput(app *tview.Application) func() {
+	restoreOutput := quietOutput()
+	previousLogOutput := log.Writer()
+	log.SetOutput(stopAppWriter{app: app, w: os.Stderr})
+
+	return func() {
+		restoreOutput()
+		log.SetOutput(previousLogOutput)
+	}
+}
+
+// stopAppWriter stops the application before writing, so log.Fatal leaves
+// the terminal usable.
+type stopAppWriter struct {
+	app *tview.Application
+	w   io.Writer
+}
+
+func (s stopAppWriter) Write(p []byte) (int, error) {
+	s.app.Stop()
+	return s.w.Write(p)
+}
+
+func (t *tui) loadPipelines(
+	ctx context.Context,
+) ([]*armdatafactory.PipelineResource, []FactoryPipelineSummary, error) {
+	pipelines, err := t.factory.source.ListPipelines(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	slices.SortFunc(pipelines, func(a, b *armdatafactory.PipelineResource) int {
+		return strings.Compare(stringValue(a.Name), stringValue(b.Name))
+	})
+
+	folders := summarizePipelineDetails(t.factory, pipelines)
+	slices.SortFunc(folders, func(a, b FactoryPipelineSummary) int {
+		return strings.Compare(a.Folder, b.Folder)
+	})
+	return pipelines, folders, nil
+}
+
+func (t *tui) loadRuns(
+	ctx context.Context,
+) ([]*armdatafactory.PipelineRun, map[string]PipelineRunSummary, error) {
+	pipelineRuns, err := fetchPipelineRuns(&t.factory, ctx, t.query, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	runs := pipelineRuns.Value
+
+	// newest first
+	slices.SortStableFunc(runs, func(a, b *armdatafactory.PipelineRun) int {
+		return compareTimes(b.RunStart, a.RunStart)
+	})
+	return runs, summarizePipelineRuns(pipelineRuns), nil
+}
+
+func (t *tui) build() {
+	t.pipelinesView = tview.NewTreeView()
+	t.pipelinesView.SetChangedFunc(func(node *tview.TreeNode) {
+		selection, ok := node.GetReference().(tuiSelection)
+		if !ok {
+			return
+		}
+		t.selection = selection
+		t.renderRuns()
+		t.renderDefinition()
+	})
+
+	t.runsView = tview.NewTable().
+		SetSelectable(true, false).
+		SetFixed(1, 0)
+	t.runsView.SetSelectionChangedFunc(func(row, column int) {
+		run, _ := t.runsView.GetCell(row, 0).GetReference().(*armdatafactory.PipelineRun)
+		t.selectedRun = run
+		t.renderActivities()
+	})
+
+	t.activitiesView = tview.NewTable().
+		SetSelectable(true, false).
+		SetFixed(1, 0)
+
+	t.definitionView = tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true)
+
+	t.statusBar = tview.NewTextView().SetDynamicColors(true)
+
+	t.filterInput = tview.NewInputField().SetLabel("filter: ")
+	t.filterInput.SetChangedFunc(func(text string) {
+		t.filter = text
+		t.renderPipelines()
+		t.renderRuns()
+	})
+	t.filterInput.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			t.filterInput.SetText("")
+		}
+		t.bottom.SwitchToPage("status")
+		t.renderStatus()
+		t.app.SetFocus(t.pipelinesView)
+	})
+
+	t.bottom = tview.NewPages().
+		AddPage("status", t.statusBar, true, true).
+		AddPage("filter", t.filterInput, true, false)
+
+	titles := []string{"[1] Pipelines", "[2] Runs", "[3] Activities", "[4] Definition"}
+	t.panes = []*tview.Box{
+		t.pipelinesView.Box,
+		t.runsView.Box,
+		t.activitiesView.Box,
+		t.definitionView.Box,
+	}
+	t.focusables = []tview.Primitive{
+		t.pipelinesView,
+		t.runsView,
+		t.activitiesView,
+		t.definitionView,
+	}
+	for i, pane := range t.panes {
+		pane.SetBorder(true).SetTitle(" " + titles[i] + " ").SetTitleAlign(tview.AlignLeft)
+		focused := pane
+		pane.SetFocusFunc(func() { focused.SetBorderColor(tcell.ColorGreen) })
+		pane.SetBlurFunc(func() { focused.SetBorderColor(tcell.ColorWhite) })
+	}
+
+	left := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(t.pipelinesView, 0, 1, true).
+		AddItem(t.runsView, 0, 1, false)
+	right := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(t.activitiesView, 0, 1, false).
+		AddItem(t.definitionView, 0, 1, false)
+	main := tview.NewFlex().
+		AddItem(left, 0, 2, true).
+		AddItem(right, 0, 3, false)
+	root := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(main, 0, 1, true).
+		AddItem(t.bottom, 1, 0, false)
+
+	t.app.SetRoot(root, true).SetFocus(t.pipelinesView)
+	t.app.SetInputCapture(t.handleKey)
+}
+
+func (t *tui) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	if t.app.GetFocus() == t.filterInput {
+		return event
+	}
+
+	switch event.Key() {
+	case tcell.KeyTab:
+		t.cycleFocus(1)
+		return nil
+	case tcell.KeyBacktab:
+		t.cycleFocus(-1)
+		return nil
+	}
+
+	switch event.Rune() {
+	case 'q':
+		t.app.Stop()
+		return nil
+	case '1', '2', '3', '4':
+		t.app.SetFocus(t.focusables[event.Rune()-'1'])
+		return nil
+	case 'j':
+		return tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone)
+	case 'k':
+		return tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModNone)
+	case '/':
+		t.bottom.SwitchToPage("filter")
+		t.app.SetFocus(t.filterInput)
+		return nil
+	case 'r':
+		go t.reload(true)
+		return nil
+	case 'm':
+		t.marked = t.selection.pipeline
+		t.renderPipelines()
+		t.renderDefinition()
+		t.renderStatus()
+		return nil
+	case 'd':
+		t.showDiff = !t.showDiff
+		t.renderDefinition()
+		return nil
+	}
+	return event
+}
+
+func (t *tui) cycleFocus(step int) {
+	current := slices.Index(t.focusables, t.app.GetFocus())
+	next := (current + step + len(t.focusables)) % len(t.focusables)
+	t.app.SetFocus(t.focusables[next])
+}
+
+func (t *tui) autoRefresh() {
+	ticker := time.NewTicker(t.refresh)
+	defer ticker.Stop()
+	for range ticker.C {
+		t.reload(false)
+	}
+}
+
+// reload fetches runs, and pipelines when requested, off the UI goroutine
+// and swaps them in once they are ready. A failed reload keeps the data
+// already shown and reports the error in the status bar.
+func (t *tui) reload(includePipelines bool) {
+	ctx := context.Background()
+	t.app.QueueUpdateDraw(func() {
+		t.statusBar.SetText("[yellow]refreshing...[-]")
+	})
+
+	var (
+		pipelines []*armdatafactory.PipelineResource
+		folders   []FactoryPipelineSummary
+		err       error
+	)
+	if includePipelines {
+		pipelines, folders, err = t.loadPipelines(ctx)
+	}
+	var (
+		runs       []*armdatafactory.PipelineRun
+		runSummary map[string]PipelineRunSummary
+	)
+	if err == nil {
+		runs, runSummary, err = t.loadRuns(ctx)
+	}
+
+	t.app.QueueUpdateDraw(func() {
+		t.refreshErr = err
+		if err != nil {
+			t.renderStatus()
+			return
+		}
+		if includePipelines {
+			t.pipelines, t.folders = pipelines, folders
+		}
+		t.runs, t.runSummary = runs, runSummary
+		t.lastRefresh = time.Now()
+
+		// runs that were still going may have new activities
+		for runID := range t.activityTrees {
+			if run := t.findRun(runID); run == nil || isRunActive(stringValue(run.Status)) {
+				delete(t.activityTrees, runID)
+			}
+		}
+
+		t.renderPipelines()
+		t.renderRuns()
+		t.renderDefinition()
+		t.renderStatus()
+	})
+}
+
+func (t *tui) findRun(runID string) *armdatafactory.PipelineRun {
+	for _, run := range t.runs {
+		if stringValue(run.RunID) == runID {
+			return run
+		}
+	}
+	return nil
+}
+
+func (t *tui) matchesFilter(pipelineName string) bool {
+	return t.filter == "" || strings.Contains(
+		strings.ToLower(pipelineName),
+		strings.ToLower(t.filter),
+	)
+}
+
+func (t *tui) renderPipelines() {
+	root := tview.NewTreeNode(t.factory.factoryName).
+		SetColor(tcell.ColorBlue).
+		SetReference(tuiSelection{})
+	current := root
+
+	for _, folder := range t.folders {
+		folderNode := tview.NewTreeNode(fmt.Sprintf(
+			"%s [gray](%d pipelines, %d activities)[-]",
+			tview.Escape(folder.Folder),
+			folder.Pipelines,
+			folder.Activities,
+		)).
+			SetColor(tcell.ColorYellow).
+			SetReference(tuiSelection{folder: folder.Folder})
+
+		for _, pipeline := range t.pipelines {
+			name := stringValue(pipeline.Name)
+			if getPipelineFolder(pipeline) != folder.Folder || !t.matchesFilter(name) {
+				continue
+			}
+
+			summary := t.runSummary[name]
+			text := fmt.Sprintf(
+				"%s [green]✔ %d[-] [red]✘ %d[-]",
+				tview.Escape(name),
+				summary.Success,
+				summary.Failed,
+			)
+			if summary.InProgress > 0 {
+				text += fmt.Sprintf(" [aqua]••• %d[-]", summary.InProgress)
+			}
+			if name == t.marked {
+				text = "[::b]*[::-] " + text
+			}
+
+			pipelineNode := tview.NewTreeNode(text).
+				SetReference(tuiSelection{folder: folder.Folder, pipeline: name})
+			folderNode.AddChild(pipelineNode)
+			if t.selection.pipeline == name {
+				current = pipelineNode
+			}
+		}
+
+		if len(folderNode.GetChildren()) == 0 && t.filter != "" {
+			continue
+		}
+		root.AddChild(folderNode)
+		if t.selection.pipeline == "" && t.selection.folder == folder.Folder {
+			current = folderNode
+		}
+	}
+
+	t.pipelinesView.SetRoot(root).SetCurrentNode(current)
+}
+
+func (t *tui) selectedRuns() []*armdatafactory.PipelineRun {
+	folderByPipeline := map[string]string{}
+	for _, pipeline := range t.pipelines {
+		folderByPipeline[stringValue(pipeline.Name)] = getPipelineFolder(pipeline)
+	}
+
+	runs := []*armdatafactory.PipelineRun{}
+	for _, run := range t.runs {
+		name := stringValue(run.PipelineName)
+		switch {
+		case !t.matchesFilter(name):
+			continue
+		case t.selection.pipeline != "" && name != t.selection.pipeline:
+			continue
+		case t.selection.folder != "" && folderByPipeline[name] != t.selection.folder:
+			continue
+		}
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+func (t *tui) renderRuns() {
+	selectedRunID := ""
+	if t.selectedRun != nil {
+		selectedRunID = stringValue(t.selectedRun.RunID)
+	}
+
+	t.runsView.Clear()
+	for column, title := range []string{"Status", "Pipeline", "Start", "Duration"} {
+		t.runsView.SetCell(0, column, tview.NewTableCell(title).
+			SetAttributes(tcell.AttrUnderline).
+			SetSelectable(false))
+	}
+
+	selectedRow := 1
+	for i, run := range t.selectedRuns() {
+		row := i + 1
+		status := stringValue(run.Status)
+		t.runsView.SetCell(row, 0, tview.NewTableCell(status).
+			SetTextColor(tuiStatusColor(status)).
+			SetReference(run))
+		t.runsView.SetCell(row, 1, tview.NewTableCell(stringValue(run.PipelineName)))
+		t.runsView.SetCell(row, 2, tview.NewTableCell(formatRunTime(run.RunStart)))
+		t.runsView.SetCell(row, 3, tview.NewTableCell(formatDurationMs(run.DurationInMs)))
+
+		if stringValue(run.RunID) == selectedRunID {
+			selectedRow = row
+		}
+	}
+
+	if t.runsView.GetRowCount() == 1 {
+		t.selectedRun = nil
+		t.renderActivities()
+		return
+	}
+	t.runsView.Select(selectedRow, 0)
+	run, _ := t.runsView.GetCell(selectedRow, 0).GetReference().(*armdatafactory.PipelineRun)
+	t.selectedRun = run
+	t.renderActivities()
+}
+
+func (t *tui) renderActivities() {
+	t.activitiesView.Clear()
+	if t.selectedRun == nil {
+		t.activitiesView.SetCell(0, 0, tview.NewTableCell("select a run").SetSelectable(false))
+		return
+	}
+
+	run := t.selectedRun
+	runID := stringValue(run.RunID)
+	tree, loaded := t.activityTrees[runID]
+	if !loaded {
+		t.activitiesView.SetCell(0, 0, tview.NewTableCell("loading activities...").SetSelectable(false))
+		t.startActivityLoad(*run)
+		return
+	}
+
+	for column, title := range []string{"Activity", "Type", "Status", "Duration", "Error"} {
+		t.activitiesView.SetCell(0, column, tview.NewTableCell(title).
+			SetAttributes(tcell.AttrUnderline).
+			SetSelectable(false))
+	}
+
+	for i, row := range flattenActivityTree(tree, "") {
+		cells := []*tview.TableCell{
+			tview.NewTableCell(row.label),
+			tview.NewTableCell(""),
+			tview.NewTableCell("not run").SetTextColor(tcell.ColorGray),
+			tview.NewTableCell(""),
+			tview.NewTableCell(""),
+		}
+		if row.run != nil {
+			status := stringValue(row.run.Status)
+			code, message := activityRunError(row.run)
+			errorText := code
+			if message != "" {
+				errorText += " " + message
+			}
+			cells[1].SetText(stringValue(row.run.ActivityType))
+			cells[2].SetText(status).SetTextColor(tuiStatusColor(status))
+			cells[3].SetText(formatDurationMs(row.run.DurationInMs))
+			cells[4].SetText(errorText).SetTextColor(tcell.ColorRed)
+		}
+		for column, cell := range cells {
+			t.activitiesView.SetCell(i+1, column, cell)
+		}
+	}
+	t.activitiesView.ScrollToBeginning()
+}
+
+// startActivityLoad loads the activities of run unless they are already
+// loading. Only one load runs at a time; selecting another run cancels it.
+func (t *tui) startActivityLoad(run armdatafactory.PipelineRun) {
+	runID := stringValue(run.RunID)
+	if t.loadingRun == runID {
+		return
+	}
+	if t.cancelActivity != nil {
+		t.cancelActivity()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.loadingRun = runID
+	t.cancelActivity = cancel
+	go t.loadActivities(ctx, run)
+}
+
+func (t *tui) loadActivities(ctx context.Context, run armdatafactory.PipelineRun) {
+	runID := stringValue(run.RunID)
+	tree, err := activityTree(&t.factory, ctx, run, activityDefinitions{})
+	t.app.QueueUpdateDraw(func() {
+		// a newer load replaced this one
+		if ctx.Err() != nil {
+			return
+		}
+		t.loadingRun = ""
+		t.cancelActivity = nil
+
+		selected := t.selectedRun != nil && stringValue(t.selectedRun.RunID) == runID
+		if err != nil {
+			if selected {
+				t.activitiesView.Clear()
+				t.activitiesView.SetCell(0, 0, tview.NewTableCell(
+					"could not load activities: "+err.Error(),
+				).SetTextColor(tcell.ColorRed).SetSelectable(false))
+			}
+			return
+		}
+		t.activityTrees[runID] = tree
+		if selected {
+			t.renderActivities()
+		}
+	})
+}
+
+func (t *tui) findPipeline(name string) *armdatafactory.PipelineResource {
+	for _, pipeline := range t.pipelines {
+		if stringValue(pipeline.Name) == name {
+			return pipeline
+		}
+	}
+	return nil
+}
+
+func (t *tui) renderDefinition() {
+	t.definitionView.Clear()
+	name := t.selection.pipeline
+	pipeline := t.findPipeline(name)
+
+	if pipeline == nil {
+		t.panes[3].SetTitle(" [4] Definition ")
+		fmt.Fprint(t.definitionView, "select a pipeline to see its definition")
+		return
+	}
+
+	if t.showDiff && t.marked != "" && t.marked != name {
+		t.panes[3].SetTitle(fmt.Sprintf(" [4] Diff %s | %s ", t.marked, name))
+		fmt.Fprint(t.definitionView, t.diffText(t.findPipeline(t.marked), pipeline))
+		t.definitionView.ScrollToBeginning()
+		return
+	}
+
+	t.panes[3].SetTitle(" [4] Definition " + name + " ")
+	definition, _ := json.MarshalIndent(
+		parsePipeline(*pipeline, []string{"id", "etag", "type"}),
+		"",
+		"  ",
+	)
+	fmt.Fprint(t.definitionView, tview.Escape(string(definition)))
+	t.definitionView.ScrollToBeginning()
+}
+
+func (t *tui) diffText(
+	marked *armdatafactory.PipelineResource,
+	selected *armdatafactory.PipelineResource,
+) string {
+	if marked == nil {
+		return "marked pipeline no longer exists"
+	}
+
+	diff := diffResources(
+		parsePipeline(*marked, comparedKeysToDrop),
+		parsePipeline(*selected, comparedKeysToDrop),
+	)
+	if diff.equal() {
+		return "[green]No differences found[-]"
+	}
+
+	sections := diffSections(diff, fmt.Sprint, fmt.Sprint)
+	for i, section := range sections {
+		sections[i] = fmt.Sprintf("[white][%d/%d][-]\n%s", i+1, len(sections), tview.Escape(section))
+	}
+	return strings.Join(sections, "\n")
+}
+
+func (t *tui) renderStatus() {
+	status := tuiHelp + "  [gray]refreshed " + t.lastRefresh.Format("15:04:05")
+	// the status bar is one line, so the error goes first
+	if t.refreshErr != nil {
+		status = "[red]refresh failed: " + tview.Escape(t.refreshErr.Error()) + "[-]  " + status
+	}
+	if t.marked != "" {
+		status += "  marked " + tview.Escape(t.marked)
+	}
+	if t.filter != "" {
+		status += "  filter " + tview.Escape(t.filter)
+	}
+	t.statusBar.SetText(status + "[-]")
+}
+
+func tuiStatusColor(status string) tcell.Color {
+	switch status {
+	case "Succeeded":
+		return tcell.ColorGreen
+	case "Failed":
+		return tcell.ColorRed
+	case "Cancelled", "Canceling":
+		return tcell.ColorYellow
+	case "InProgress", "Queued":
+		return tcell.ColorAqua
+	}
+	return tcell.ColorWhite
+}
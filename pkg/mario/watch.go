@@ -0,0 +1,285 @@
+package mario
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/datafactory/armdatafactory/v3"
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+)
+
+var activeRunStatuses = []string{"Queued", "InProgress", "Canceling"}
+
+type watchedRun struct {
+	run *armdatafactory.PipelineRun
+	// when watch first saw the run in a terminal state
+	finishedAt time.Time
+}
+
+// Watch polls for queued and in progress runs and redraws a live table until
+// interrupted. The first poll reads every active run in the query's range;
+// later polls only ask for runs updated since the previous successful poll.
+// A failed poll is shown above the table and retried on the next tick.
+func Watch(query RunQuery, name string, interval time.Duration, historyDays int) {
+	loc, err := query.location()
+	if err != nil {
+		log.Fatal(err)
+	}
+	factory := getFactoryClient()
+	ctx := context.Background()
+
+	stopCh := make(chan os.Signal, 1)
+	signal.Notify(stopCh, syscall.SIGINT, syscall.SIGTERM)
+
+	expected, err := expectedDurations(&factory, ctx, historyDays, name)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	restoreOutput := quietOutput()
+	defer restoreOutput()
+
+	watched := map[string]*watchedRun{}
+	activeQuery := query
+	activeQuery.Statuses = activeRunStatuses
+	var since time.Time
+	var pollErr error
+	poll := func() {
+		pollStart := time.Now()
+		pollQuery := activeQuery
+		if !since.IsZero() {
+			// overlap polls slightly so a run updated during a query is not lost
+			pollQuery = RunQuery{
+				From:     since.Add(-interval / 2).Format(time.RFC3339),
+				Timezone: query.Timezone,
+			}
+		}
+		runs, err := fetchPipelineRuns(&factory, ctx, pollQuery, "")
+		pollErr = err
+		if err != nil {
+			return
+		}
+		since = pollStart
+		mergeWatchedRuns(watched, runs.Value, name, pollStart)
+	}
+	poll()
+
+	// finished runs stay highlighted for a few polls before they drop off
+	linger := max(3*interval, time.Minute)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		printWatch(name, watched, expected, interval, loc, pollErr)
+
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		poll()
+
+		for runID, watchedRun := range watched {
+			if !watchedRun.finishedAt.IsZero() && time.Since(watchedRun.finishedAt) > linger {
+				delete(watched, runID)
+			}
+		}
+	}
+}
+
+// mergeWatchedRuns folds a poll into the watched runs. Finished runs are only
+// kept if watch saw them while they were active.
+func mergeWatchedRuns(
+	watched map[string]*watchedRun,
+	runs []*armdatafactory.PipelineRun,
+	name string,
+	now time.Time,
+) {
+	for _, run := range runs {
+		if !strings.Contains(stringValue(run.PipelineName), name) {
+			continue
+		}
+
+		runID := stringValue(run.RunID)
+		existing, exists := watched[runID]
+		active := isRunActive(stringValue(run.Status))
+
+		switch {
+		case active:
+			watched[runID] = &watchedRun{run: run}
+		case exists && existing.finishedAt.IsZero():
+			watched[runID] = &watchedRun{run: run, finishedAt: now}
+		}
+	}
+}
+
+// expectedDurations is the median duration of each pipeline's successful runs
+// over the history window, used to estimate progress.
+func expectedDurations(
+	factory *Factory,
+	ctx context.Context,
+	historyDays int,
+	name string,
+) (map[string]time.Duration, error) {
+	history, err := fetchPipelineRuns(factory, ctx, RunQuery{
+		Days:     historyDays,
+		Statuses: []string{"Succeeded"},
+	}, "")
+	if err != nil {
+		return nil, err
+	}
+
+	durationsByPipeline := map[string][]float64{}
+	for _, run := range history.Value {
+		pipelineName := stringValue(run.PipelineName)
+		if run.DurationInMs == nil || !strings.Contains(pipelineName, name) {
+			continue
+		}
+		durationsByPipeline[pipelineName] = append(
+			durationsByPipeline[pipelineName],
+			float64(*run.DurationInMs),
+		)
+	}
+
+	expected := map[string]time.Duration{}
+	for pipelineName, durations := range durationsByPipeline {
+		expected[pipelineName] = time.Duration(median(durations)) * time.Millisecond
+	}
+	return expected, nil
+}
+
+func printWatch(
+	name string,
+	watched map[string]*watchedRun,
+	expected map[string]time.Duration,
+	interval time.Duration,
+	loc *time.Location,
+	pollErr error,
+) {
+	headerLength := 80
+
+	header := createHeader(
+		"WATCH",
+		headerLength,
+		color.New(color.FgBlue),
+		"=",
+		true,
+	)
+	footer := createHeader("", headerLength, color.New(color.FgWhite), "=", true)
+
+	// clear the screen and move the cursor home before redrawing
+	fmt.Print("\033[H\033[2J")
+	fmt.Println(header)
+	fmt.Printf(
+		"%s  every %s  ctrl+c to stop\n",
+		time.Now().In(loc).Format("2006-01-02 15:04:05"),
+		interval,
+	)
+	if name != "" {
+		fmt.Println("pipelines matching", name)
+	}
+	if pollErr != nil {
+		fmt.Println(failureColor()("poll failed, retrying: " + pollErr.Error()))
+	}
+	fmt.Println()
+
+	if len(watched) == 0 {
+		fmt.Println("No queued or in progress runs")
+		fmt.Println(footer)
+		return
+	}
+
+	runs := make([]*watchedRun, 0, len(watched))
+	for _, watchedRun := range watched {
+		runs = append(runs, watchedRun)
+	}
+	slices.SortFunc(runs, func(a, b *watchedRun) int {
+		return compareTimes(a.run.RunStart, b.run.RunStart)
+	})
+
+	headerFmt := color.New(color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+	tbl := table.New(
+		"Pipeline",
+		"Status",
+		"Started",
+		"Elapsed",
+		"Expected",
+		"Progress",
+	)
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	for _, watchedRun := range runs {
+		run := watchedRun.run
+		status := stringValue(run.Status)
+
+		elapsed := time.Duration(0)
+		switch {
+		case run.DurationInMs != nil:
+			elapsed = time.Duration(*run.DurationInMs) * time.Millisecond
+		case run.RunStart != nil:
+			elapsed = time.Since(*run.RunStart)
+		}
+
+		expectedDuration, hasHistory := expected[stringValue(run.PipelineName)]
+		expectedFormatted := "-"
+		progress := neutralColor()("no history")
+		if hasHistory {
+			expectedFormatted = expectedDuration.Truncate(time.Second).String()
+			progress = progressBar(elapsed, expectedDuration)
+		}
+
+		statusFormatted := statusColor(status)(status)
+		switch {
+		case status == "Succeeded":
+			statusFormatted = successColor()("✔ finished")
+			progress = successColor()(progressBar(1, 1))
+		case status == "Failed":
+			statusFormatted = failureColor()("✘ failed")
+		case !isRunActive(status):
+			statusFormatted = statusColor(status)("• " + strings.ToLower(status))
+		}
+
+		tbl.AddRow(
+			stringValue(run.PipelineName),
+			statusFormatted,
+			formatRunTime(run.RunStart),
+			elapsed.Truncate(time.Second).String(),
+			expectedFormatted,
+			progress,
+		)
+	}
+	tbl.Print()
+
+	fmt.Println(footer)
+}
+
+// progressBar estimates how far along a run is from its elapsed time and the
+// pipeline's typical duration. Runs past their typical duration are flagged.
+func progressBar(elapsed time.Duration, expected time.Duration) string {
+	barLength := 20
+	if expected <= 0 {
+		return ""
+	}
+
+	ratio := float64(elapsed) / float64(expected)
+	if ratio > 1 {
+		overdue := fmt.Sprintf("overdue +%s", (elapsed - expected).Truncate(time.Second))
+		return color.New(color.FgYellow).Sprint(strings.Repeat("▰", barLength), " ", overdue)
+	}
+
+	filled := int(ratio * float64(barLength))
+	return strings.Repeat("▰", filled) +
+		strings.Repeat("▱", barLength-filled) +
+		fmt.Sprintf(" %3.0f%%", ratio*100)
+}